@@ -2,7 +2,11 @@ package pongo2
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"errors"
 )
@@ -40,6 +44,32 @@ func (c Context) checkForValidIdentifiers() *Error {
 	return nil
 }
 
+// Validate checks the context upfront (instead of lazily while rendering):
+// all keys must be valid identifiers and no value may be of a type pongo2
+// can't handle (channels and unsafe pointers).
+func (c Context) Validate() *Error {
+	if err := c.checkForValidIdentifiers(); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch kind := reflect.ValueOf(c[k]).Kind(); kind {
+		case reflect.Chan, reflect.UnsafePointer:
+			return &Error{
+				Sender:    "validate",
+				OrigError: fmt.Errorf("context-key '%s' has a value of unsupported type %s", k, kind),
+			}
+		}
+	}
+	return nil
+}
+
 // Update updates this context with the key/value-pairs from another context.
 func (c Context) Update(other Context) Context {
 	for k, v := range other {
@@ -70,6 +100,46 @@ type ExecutionContext struct {
 	Public     Context
 	Private    Context
 	Shared     Context
+
+	// Render timeout (see TemplateSet.SetRenderTimeout), nil if disabled
+	deadline *renderDeadline
+
+	// Options of the executed template (TrimBlocks and LStripBlocks apply to
+	// the HTML of its whole rendering); nil means the defaults
+	options *Options
+}
+
+// renderDeadline is shared by all ExecutionContexts of one execution; a
+// timer marks it as exceeded.
+type renderDeadline struct {
+	timeout  time.Duration
+	exceeded int32
+	timer    *time.Timer
+}
+
+func newRenderDeadline(timeout time.Duration) *renderDeadline {
+	d := &renderDeadline{timeout: timeout}
+	d.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&d.exceeded, 1)
+	})
+	return d
+}
+
+func (d *renderDeadline) stop() {
+	d.timer.Stop()
+}
+
+// checkDeadline returns an error if the render timeout has been exceeded.
+func (ctx *ExecutionContext) checkDeadline() *Error {
+	if ctx.deadline == nil || atomic.LoadInt32(&ctx.deadline.exceeded) == 0 {
+		return nil
+	}
+	return &Error{
+		Template:  ctx.template,
+		Filename:  ctx.template.name,
+		Sender:    "timeout",
+		OrigError: fmt.Errorf("rendering exceeded the timeout of %s", ctx.deadline.timeout),
+	}
 }
 
 var pongo2MetaContext = Context{
@@ -80,13 +150,16 @@ func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
 	privateCtx := make(Context)
 
 	// Make the pongo2-related funcs/vars available to the context
-	privateCtx["pongo2"] = pongo2MetaContext
+	if tpl == nil || !tpl.set.hideMeta {
+		privateCtx["pongo2"] = pongo2MetaContext
+	}
 
 	return &ExecutionContext{
 		template: tpl,
 
 		Public:     ctx,
 		Private:    privateCtx,
+		Shared:     make(Context),
 		Autoescape: autoescape,
 	}
 }
@@ -98,6 +171,8 @@ func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 		Public:     parent.Public,
 		Private:    make(Context),
 		Autoescape: parent.Autoescape,
+		deadline:   parent.deadline,
+		options:    parent.options,
 	}
 	newctx.Shared = parent.Shared
 
@@ -107,6 +182,133 @@ func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	return newctx
 }
 
+// SetShared stores a value in the Shared context. Shared values live for the
+// whole execution of the template: they're visible to all tags, including
+// those executed within child contexts (like loop bodies), but not to the
+// user's template. Included templates get their own Shared context.
+func (ctx *ExecutionContext) SetShared(key string, val interface{}) {
+	ctx.Shared[key] = val
+}
+
+// GetShared returns a value stored with SetShared.
+func (ctx *ExecutionContext) GetShared(key string) (interface{}, bool) {
+	val, has := ctx.Shared[key]
+	return val, has
+}
+
+// SetPrivate stores a value in the Private context, making it available to
+// the user's template as a variable. Private values are scoped: child
+// contexts (like loop bodies) start with a copy of their parent's Private
+// context, so values set within them are dropped when the scope ends.
+func (ctx *ExecutionContext) SetPrivate(key string, val interface{}) {
+	ctx.Private[key] = val
+}
+
+// GetPrivate returns a value of the Private context (see SetPrivate).
+func (ctx *ExecutionContext) GetPrivate(key string) (interface{}, bool) {
+	val, has := ctx.Private[key]
+	return val, has
+}
+
+// NewIsolatedExecutionContext creates a child context which doesn't see any
+// of the parent's data: Public is replaced by the given context (nil means
+// empty) and Private starts fresh. Shared (and with it the values of the
+// increment- and append-tags) and the template are still shared with the
+// parent. It's meant for tags rendering sub-templates in a sandbox.
+func NewIsolatedExecutionContext(parent *ExecutionContext, public Context) *ExecutionContext {
+	if public == nil {
+		public = make(Context)
+	}
+	newctx := newExecutionContext(parent.template, public)
+	newctx.Shared = parent.Shared
+	newctx.Autoescape = parent.Autoescape
+	newctx.deadline = parent.deadline
+	newctx.options = parent.options
+	return newctx
+}
+
+// contextAccumulatorsKey is the Shared-context key holding the values
+// maintained by the increment and append tags.
+const contextAccumulatorsKey = "_pongo2_accumulators"
+
+// accumulators are the values of the increment and append tags of a
+// rendering. Every value they stored is remembered, so a (stale) copy bound
+// in a private context can be told apart from a local variable.
+type accumulators struct {
+	values map[string]*Value
+	stored map[*Value]bool
+}
+
+func (ctx *ExecutionContext) accumulatedValue(name string) (*Value, bool) {
+	acc, ok := ctx.Shared[contextAccumulatorsKey].(*accumulators)
+	if !ok {
+		return nil, false
+	}
+	val, ok := acc.values[name]
+	return val, ok
+}
+
+// setAccumulatedValue stores the value for the rest of the rendering
+// (across all scopes) and binds it to the current scope as well.
+func (ctx *ExecutionContext) setAccumulatedValue(name string, value *Value) {
+	if ctx.Shared == nil {
+		ctx.Shared = make(Context)
+	}
+	acc, ok := ctx.Shared[contextAccumulatorsKey].(*accumulators)
+	if !ok {
+		acc = &accumulators{values: make(map[string]*Value), stored: make(map[*Value]bool)}
+		ctx.Shared[contextAccumulatorsKey] = acc
+	}
+	acc.values[name] = value
+	acc.stored[value] = true
+	ctx.Private[name] = value
+}
+
+// privateValue returns the value bound to name in the private context or,
+// if there's none, the accumulated value. Local variables (like macro
+// arguments) shadow accumulated values; an accumulated value bound by an
+// outer scope, however, is replaced by its current value.
+func (ctx *ExecutionContext) privateValue(name string) (interface{}, bool) {
+	accumulated, hasAccumulated := ctx.accumulatedValue(name)
+	val, ok := ctx.Private[name]
+	if !ok {
+		return accumulated, hasAccumulated
+	}
+	if v, isValue := val.(*Value); isValue && hasAccumulated &&
+		ctx.Shared[contextAccumulatorsKey].(*accumulators).stored[v] {
+		return accumulated, true
+	}
+	return val, true
+}
+
+// lookupValue returns the value bound to name as seen by a template variable
+// lookup (private context including the accumulated values, public context).
+func (ctx *ExecutionContext) lookupValue(name string) *Value {
+	val, ok := ctx.privateValue(name)
+	if !ok {
+		val = ctx.Public[name]
+	}
+	if v, ok := val.(*Value); ok {
+		return v
+	}
+	return AsValue(val)
+}
+
+// escapeModeEscapes reports whether the template set's escape mode allows
+// the value to be auto-escaped at all.
+func (ctx *ExecutionContext) escapeModeEscapes(value *Value) bool {
+	if ctx.template == nil {
+		return true
+	}
+	switch ctx.template.set.escapeMode {
+	case ModeEscapeNone:
+		return false
+	case ModeEscapeMarked:
+		return value.unsafe
+	}
+	return true
+}
+
 func (ctx *ExecutionContext) Error(msg string, token *Token) *Error {
 	return ctx.OrigError(errors.New(msg), token)
 }
@@ -20,6 +20,76 @@ type Error struct {
 	Token     *Token
 	Sender    string
 	OrigError error
+
+	// Phase in which the error occurred (lexing, parsing or execution)
+	Phase ErrorPhase
+
+	// Frames (includes, macros, blocks) the error passed through, innermost first
+	stack []string
+}
+
+// ErrorPhase tells whether an error stems from lexing, parsing or executing
+// a template; it allows callers to tell syntax errors in user-provided
+// templates apart from errors at runtime.
+type ErrorPhase int
+
+const (
+	// PhaseUnknown is used for errors which happen outside of the template
+	// processing, like a template loader failing to open a file.
+	PhaseUnknown ErrorPhase = iota
+	PhaseLex
+	PhaseParse
+	PhaseExecute
+)
+
+func (p ErrorPhase) String() string {
+	switch p {
+	case PhaseLex:
+		return "lex"
+	case PhaseParse:
+		return "parse"
+	case PhaseExecute:
+		return "execute"
+	}
+	return "unknown"
+}
+
+// withPhase sets the error's phase unless it has been set already (for
+// example by a nested template's parser).
+func (e *Error) withPhase(phase ErrorPhase) *Error {
+	if e.Phase == PhaseUnknown {
+		e.Phase = phase
+	}
+	return e
+}
+
+// errorWithPhase is like Error.withPhase for any error; errors of other
+// types are returned as they are.
+func errorWithPhase(err error, phase ErrorPhase) error {
+	if e, ok := err.(*Error); ok && e != nil {
+		e.withPhase(phase)
+	}
+	return err
+}
+
+// Stack returns the chain of includes, macros and blocks (outermost first)
+// which were being executed when the error occurred, like:
+//
+//	include 'widgets.tpl'
+//	macro 'render_item'
+func (e *Error) Stack() []string {
+	stack := make([]string, 0, len(e.stack))
+	for i := len(e.stack) - 1; i >= 0; i-- {
+		stack = append(stack, e.stack[i])
+	}
+	return stack
+}
+
+// addFrame records that the error passed through the given frame while
+// unwinding the execution.
+func (e *Error) addFrame(format string, args ...interface{}) *Error {
+	e.stack = append(e.stack, fmt.Sprintf(format, args...))
+	return e
 }
 
 func (e *Error) updateFromTokenIfNeeded(template *Template, t *Token) *Error {
@@ -38,6 +108,13 @@ func (e *Error) updateFromTokenIfNeeded(template *Template, t *Token) *Error {
 	return e
 }
 
+// Unwrap returns the original error, so errors.Is and errors.As can be
+// used to check for the error which caused the template error (like an error
+// returned by a filter, a function call or a template loader).
+func (e *Error) Unwrap() error {
+	return e.OrigError
+}
+
 // Returns a nice formatted error string.
 func (e *Error) Error() string {
 	s := "[Error"
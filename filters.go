@@ -2,45 +2,209 @@ package pongo2
 
 import (
 	"fmt"
+	"sync"
 )
 
 // FilterFunction is the type filter functions must fulfil
 type FilterFunction func(in *Value, param *Value) (out *Value, err *Error)
 
-var filters map[string]FilterFunction
+// FilterKwargsFunction is the type of filter functions taking keyword
+// arguments (see RegisterFilterKwargs). Omitted arguments are missing in
+// kwargs.
+type FilterKwargsFunction func(in *Value, kwargs map[string]*Value) (out *Value, err *Error)
+
+// FilterWithContextFunction is the type of filter functions which need access
+// to the execution context (see RegisterFilterWithContext).
+type FilterWithContextFunction func(ctx *ExecutionContext, in *Value, param *Value) (out *Value, err *Error)
+
+var (
+	filters            map[string]FilterFunction
+	filtersKwargs      map[string]FilterKwargsFunction
+	filtersWithContext map[string]FilterWithContextFunction
+
+	// Guards filters, filtersKwargs, filtersWithContext and filterArguments;
+	// filters might be registered lazily, concurrently to template parsing
+	// and execution
+	filtersMutex sync.RWMutex
+)
+
+// filterArgument declares whether a (built-in) filter expects an argument.
+// It's checked when Options.StrictFilterArguments is enabled.
+type filterArgument int
+
+const (
+	filterArgumentOptional filterArgument = iota
+	filterArgumentRequired
+	filterArgumentNone
+)
+
+var filterArguments map[string]filterArgument
+
+// debugFilters are built-in filters which are only available to template sets
+// in debug mode (like dump, which exposes the structure of the data).
+var debugFilters = map[string]bool{
+	"dump": true,
+}
+
+// pureFilters are built-in filters whose result only depends on the input and
+// the argument (not on the time, randomness or any setting); applied to
+// literals, they are evaluated once at parse time (see foldConstants).
+var pureFilters = map[string]bool{
+	"add": true, "addslashes": true, "apnumber": true, "base62decode": true, "base62encode": true, "capfirst": true, "center": true, "columns": true,
+	"contains": true, "cut": true, "default": true, "default_if_error": true, "default_if_none": true,
+	"divisibleby": true, "endswith": true, "escape": true, "e": true, "escapejs": true,
+	"bool": true, "first": true, "float": true, "floatformat": true, "get_digit": true,
+	"int": true, "integer": true, "intcomma": true, "intword": true, "last": true, "length": true, "length_is": true, "ljust": true,
+	"json_canonical": true, "lookup": true, "lower": true, "mask": true, "numberformat": true, "ordinal": true, "percentage": true, "pluck": true, "pluralize": true, "replace": true, "rjust": true, "safe": true,
+	"startswith": true, "string": true, "stringformat": true, "striptags": true,
+	"title": true, "truncate_sentences": true, "truncatechars": true, "truncatewords": true, "upper": true,
+	"urlencode": true, "wordcount": true, "yesno": true, "zip": true,
+}
+
+// multiArgumentFilters are built-in filters which take several arguments,
+// separated by colons ({{ s|replace:"foo":"bar":1 }}). Called with more than
+// one argument, they get the arguments as filterParameters.
+var multiArgumentFilters = map[string]bool{
+	"center": true, "coalesce": true, "columns": true, "contains": true, "endswith": true, "join": true,
+	"ljust": true, "localtime": true, "mask": true, "numberformat": true, "percentage": true,
+	"pluck": true, "replace": true, "rjust": true, "startswith": true, "truncate": true,
+	"truncate_middle": true, "tz": true,
+}
+
+// filterParameters is the param of a filter called with several arguments
+// (see multiArgumentFilters).
+type filterParameters []*Value
+
+// filterParameterList returns the arguments a filter has been called with.
+func filterParameterList(param *Value) []*Value {
+	if params, ok := param.Interface().(filterParameters); ok {
+		return params
+	}
+	return []*Value{param}
+}
 
 func init() {
 	filters = make(map[string]FilterFunction)
+	filtersKwargs = make(map[string]FilterKwargsFunction)
+	filtersWithContext = make(map[string]FilterWithContextFunction)
+	filterArguments = make(map[string]filterArgument)
 }
 
 // FilterExists returns true if the given filter is already registered
+// (with RegisterFilter, RegisterFilterKwargs or RegisterFilterWithContext)
 func FilterExists(name string) bool {
-	_, existing := filters[name]
-	return existing
+	_, existing := lookupFilter(name)
+	_, existingKwargs := lookupFilterKwargs(name)
+	_, existingWithContext := lookupFilterWithContext(name)
+	return existing || existingKwargs || existingWithContext
+}
+
+// lookupFilter returns the globally registered filter function.
+func lookupFilter(name string) (FilterFunction, bool) {
+	filtersMutex.RLock()
+	defer filtersMutex.RUnlock()
+	fn, existing := filters[name]
+	return fn, existing
+}
+
+// lookupFilterKwargs returns the globally registered keyword arguments filter.
+func lookupFilterKwargs(name string) (FilterKwargsFunction, bool) {
+	filtersMutex.RLock()
+	defer filtersMutex.RUnlock()
+	fn, existing := filtersKwargs[name]
+	return fn, existing
+}
+
+// lookupFilterWithContext returns the globally registered context-aware filter.
+func lookupFilterWithContext(name string) (FilterWithContextFunction, bool) {
+	filtersMutex.RLock()
+	defer filtersMutex.RUnlock()
+	fn, existing := filtersWithContext[name]
+	return fn, existing
 }
 
 // RegisterFilter registers a new filter. If there's already a filter with the same
-// name, RegisterFilter will panic. You usually want to call this
+// name, RegisterFilter returns an error and keeps the existing filter. You usually want to call this
 // function in the filter's init() function:
 // http://golang.org/doc/effective_go.html#init
 //
+// Libraries can prefix their filters with a namespace to avoid collisions,
+// like RegisterFilter("myorg.slugify", fn) used as {{ title|myorg.slugify }}.
+//
 // See http://www.florian-schlachter.de/post/pongo2/ for more about
 // writing filters and tags.
 func RegisterFilter(name string, fn FilterFunction) error {
-	if FilterExists(name) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	_, existing := filters[name]
+	_, existingWithContext := filtersWithContext[name]
+	if existing || existingWithContext {
 		return fmt.Errorf("filter with name '%s' is already registered", name)
 	}
 	filters[name] = fn
 	return nil
 }
 
+// TryRegisterFilter registers a new filter like RegisterFilter and returns an
+// error (keeping the existing filter) if the name is already taken. It's meant
+// for plugins which can't know which filters have been registered already.
+func TryRegisterFilter(name string, fn FilterFunction) error {
+	return RegisterFilter(name, fn)
+}
+
+// RegisterFilterKwargs registers a filter which is called with keyword
+// arguments:
+//
+//	{{ text|truncate(length=50, end="…") }}
+//
+// The arguments can be given in any order; each one is an expression. A
+// keyword arguments filter can coexist with a regular filter of the same
+// name (registered with RegisterFilter): the colon form {{ text|truncate:50 }}
+// calls the regular filter, the parenthesized form calls the keyword
+// arguments filter. A keyword arguments filter without a regular counterpart
+// can also be used without any arguments ({{ value|name }}), but not in
+// the colon form. An error is returned if there's already a keyword
+// arguments filter with the same name.
+func RegisterFilterKwargs(name string, fn FilterKwargsFunction) error {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, existing := filtersKwargs[name]; existing {
+		return fmt.Errorf("keyword arguments filter with name '%s' is already registered", name)
+	}
+	filtersKwargs[name] = fn
+	return nil
+}
+
+// RegisterFilterWithContext registers a filter which gets access to the
+// ExecutionContext of the template being executed (for example to read
+// values of the context or to render templates). It's used like any other
+// filter; it can't be applied through ApplyFilter() though, since there's no
+// execution context. Like RegisterFilter, it returns an error if there's
+// already a filter with the same name.
+func RegisterFilterWithContext(name string, fn FilterWithContextFunction) error {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	_, existing := filters[name]
+	_, existingWithContext := filtersWithContext[name]
+	if existing || existingWithContext {
+		return fmt.Errorf("filter with name '%s' is already registered", name)
+	}
+	filtersWithContext[name] = fn
+	return nil
+}
+
 // ReplaceFilter replaces an already registered filter with a new implementation. Use this
 // function with caution since it allows you to change existing filter behaviour.
 func ReplaceFilter(name string, fn FilterFunction) error {
-	if !FilterExists(name) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, existing := filters[name]; !existing {
 		return fmt.Errorf("filter with name '%s' does not exist (therefore cannot be overridden)", name)
 	}
 	filters[name] = fn
+	delete(filterArguments, name) // the new implementation may take other arguments
+	delete(debugFilters, name)
+	delete(pureFilters, name)
 	return nil
 }
 
@@ -56,7 +220,11 @@ func MustApplyFilter(name string, value *Value, param *Value) *Value {
 // ApplyFilter applies a filter to a given value using the given parameters.
 // Returns a *pongo2.Value or an error.
 func ApplyFilter(name string, value *Value, param *Value) (*Value, *Error) {
-	fn, existing := filters[name]
+	fn, existing := lookupFilter(name)
+	return applyFilter(name, fn, existing, value, param)
+}
+
+func applyFilter(name string, fn FilterFunction, existing bool, value *Value, param *Value) (*Value, *Error) {
 	if !existing {
 		return nil, &Error{
 			Sender:    "applyfilter",
@@ -78,32 +246,100 @@ type filterCall struct {
 	name      string
 	parameter IEvaluator
 
+	// The further arguments of multiArgumentFilters
+	moreParameters []IEvaluator
+
 	filterFunc FilterFunction
+
+	// Set instead of filterFunc for filters registered with
+	// RegisterFilterWithContext
+	contextFunc FilterWithContextFunction
+
+	// Set for the keyword arguments form: name(key=expr, ...)
+	kwargs     map[string]IEvaluator
+	kwargsFunc FilterKwargsFunction
+
+	// Whether it's one of the pureFilters
+	pure bool
+
+	// Set for default_if_error, which catches the errors of the preceding
+	// expression and filters
+	catchesErrors bool
 }
 
 func (fc *filterCall) Execute(v *Value, ctx *ExecutionContext) (*Value, *Error) {
-	var param *Value
-	var err *Error
+	if fc.kwargsFunc != nil {
+		return fc.executeKwargs(v, ctx)
+	}
 
-	if fc.parameter != nil {
-		param, err = fc.parameter.Evaluate(ctx)
+	param, err := evaluateFilterParameters(ctx, fc.parameter, fc.moreParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredValue *Value
+	if fc.contextFunc != nil {
+		filteredValue, err = fc.contextFunc(ctx, v, param)
+	} else {
+		filteredValue, err = fc.filterFunc(v, param)
+	}
+	if err != nil {
+		return nil, err.updateFromTokenIfNeeded(ctx.template, fc.token)
+	}
+	return filteredValue, nil
+}
+
+// evaluateFilterParameters evaluates the argument(s) of a filter call; several
+// arguments are passed as filterParameters.
+func evaluateFilterParameters(ctx *ExecutionContext, parameter IEvaluator, moreParameters []IEvaluator) (*Value, *Error) {
+	if parameter == nil {
+		return AsValue(nil), nil
+	}
+	param, err := parameter.Evaluate(ctx)
+	if err != nil || len(moreParameters) == 0 {
+		return param, err
+	}
+
+	params := filterParameters{param}
+	for _, expr := range moreParameters {
+		value, err := expr.Evaluate(ctx)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		param = AsValue(nil)
+		params = append(params, value)
+	}
+	return AsValue(params), nil
+}
+
+// executeOnError returns the parameter of default_if_error, replacing the
+// failed evaluation of the preceding expression.
+func (fc *filterCall) executeOnError(ctx *ExecutionContext) (*Value, *Error) {
+	if fc.parameter == nil {
+		return AsValue(nil), nil
+	}
+	return fc.parameter.Evaluate(ctx)
+}
+
+func (fc *filterCall) executeKwargs(v *Value, ctx *ExecutionContext) (*Value, *Error) {
+	kwargs := make(map[string]*Value, len(fc.kwargs))
+	for name, expr := range fc.kwargs {
+		value, err := expr.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		kwargs[name] = value
 	}
 
-	filteredValue, err := fc.filterFunc(v, param)
+	filteredValue, err := fc.kwargsFunc(v, kwargs)
 	if err != nil {
 		return nil, err.updateFromTokenIfNeeded(ctx.template, fc.token)
 	}
 	return filteredValue, nil
 }
 
-// Filter = IDENT | IDENT ":" FilterArg | IDENT "|" Filter
+// Filter = IDENT | IDENT ":" FilterArg { ":" FilterArg } | IDENT "(" Kwargs ")" | IDENT "|" Filter
 func (p *Parser) parseFilter() (*filterCall, *Error) {
-	identToken := p.MatchType(TokenIdentifier)
+	identToken := p.matchQualifiedName()
 
 	// Check filter ident
 	if identToken == nil {
@@ -116,12 +352,54 @@ func (p *Parser) parseFilter() (*filterCall, *Error) {
 	}
 
 	// Get the appropriate filter function and bind it
-	filterFn, exists := filters[identToken.Val]
-	if !exists {
+	filterFn, exists := p.template.set.filter(identToken.Val)
+	kwargsFn, kwargsExists := lookupFilterKwargs(identToken.Val)
+	contextFn, contextExists := lookupFilterWithContext(identToken.Val)
+	if _, isLocal := p.template.set.filters[identToken.Val]; isLocal {
+		// a set-local filter hides the global ones
+		kwargsExists, contextExists = false, false
+	}
+	if contextExists {
+		filter.contextFunc = contextFn
+		exists = true
+	}
+	if !exists && !kwargsExists {
 		return nil, p.Error(fmt.Sprintf("Filter '%s' does not exist.", identToken.Val), identToken)
 	}
 
+	if err := p.checkDebugFilter(identToken); err != nil {
+		return nil, err
+	}
+
+	// Keyword arguments
+	if p.Peek(TokenSymbol, "(") != nil {
+		if !kwargsExists {
+			return nil, p.Error(fmt.Sprintf("Filter '%s' does not take keyword arguments.", identToken.Val), identToken)
+		}
+		kwargs, err := p.parseFilterKwargs()
+		if err != nil {
+			return nil, err
+		}
+		filter.kwargs = kwargs
+		filter.kwargsFunc = kwargsFn
+		return filter, nil
+	}
+	if !exists {
+		if p.Peek(TokenSymbol, ":") != nil {
+			return nil, p.Error(fmt.Sprintf("Filter '%s' only takes keyword arguments, like %s(name=value).", identToken.Val, identToken.Val), identToken)
+		}
+		filter.kwargs = make(map[string]IEvaluator)
+		filter.kwargsFunc = kwargsFn
+		return filter, nil
+	}
+
 	filter.filterFunc = filterFn
+	if _, isLocal := p.template.set.filters[identToken.Val]; !isLocal && filter.contextFunc == nil {
+		filtersMutex.RLock()
+		filter.pure = pureFilters[identToken.Val]
+		filtersMutex.RUnlock()
+		filter.catchesErrors = identToken.Val == "default_if_error"
+	}
 
 	// Check for filter-argument (2 tokens needed: ':' ARG)
 	if p.Match(TokenSymbol, ":") != nil {
@@ -135,7 +413,112 @@ func (p *Parser) parseFilter() (*filterCall, *Error) {
 			return nil, err
 		}
 		filter.parameter = v
+
+		filter.moreParameters, err = p.parseMoreFilterArguments(identToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.checkFilterArgument(identToken, filter.parameter != nil); err != nil {
+		return nil, err
 	}
 
 	return filter, nil
 }
+
+// parseMoreFilterArguments parses the further colon-separated arguments of
+// the multiArgumentFilters (set-local filters take a single argument).
+func (p *Parser) parseMoreFilterArguments(nameToken *Token) ([]IEvaluator, *Error) {
+	if _, isLocal := p.template.set.filters[nameToken.Val]; isLocal {
+		return nil, nil
+	}
+	filtersMutex.RLock()
+	multiArgument := multiArgumentFilters[nameToken.Val]
+	filtersMutex.RUnlock()
+
+	var exprs []IEvaluator
+	for multiArgument && p.Match(TokenSymbol, ":") != nil {
+		expr, err := p.parseVariableOrLiteral()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// Kwargs = "(" [ IDENT "=" Expression { "," IDENT "=" Expression } ] ")"
+func (p *Parser) parseFilterKwargs() (map[string]IEvaluator, *Error) {
+	p.Match(TokenSymbol, "(")
+	kwargs := make(map[string]IEvaluator)
+	for p.Match(TokenSymbol, ")") == nil {
+		if len(kwargs) > 0 && p.Match(TokenSymbol, ",") == nil {
+			return nil, p.Error("Expected ',' or ')' after keyword argument.", nil)
+		}
+		nameToken := p.MatchType(TokenIdentifier)
+		if nameToken == nil {
+			return nil, p.Error("Expected the name of a keyword argument (identifier).", nil)
+		}
+		if _, has := kwargs[nameToken.Val]; has {
+			return nil, p.Error(fmt.Sprintf("Keyword argument '%s' given twice.", nameToken.Val), nameToken)
+		}
+		if p.Match(TokenSymbol, "=") == nil {
+			return nil, p.Error("Expected '=' after the name of the keyword argument.", nil)
+		}
+		expr, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		kwargs[nameToken.Val] = expr
+	}
+	return kwargs, nil
+}
+
+// checkDebugFilter rejects the built-in debug filters (see debugFilters) if
+// the template set isn't in debug mode.
+func (p *Parser) checkDebugFilter(nameToken *Token) *Error {
+	if p.template.set.Debug {
+		return nil
+	}
+	if _, isLocal := p.template.set.filters[nameToken.Val]; isLocal {
+		return nil
+	}
+
+	filtersMutex.RLock()
+	debugOnly := debugFilters[nameToken.Val]
+	filtersMutex.RUnlock()
+
+	if debugOnly {
+		return p.Error(fmt.Sprintf("Filter '%s' is only available in debug mode (TemplateSet.Debug).", nameToken.Val), nameToken)
+	}
+	return nil
+}
+
+// checkFilterArgument validates the presence of a filter's argument against
+// the declaration of the built-in filter (if Options.StrictFilterArguments
+// is enabled). Set-local filters are not checked.
+func (p *Parser) checkFilterArgument(nameToken *Token, hasArgument bool) *Error {
+	if !p.template.Options.StrictFilterArguments {
+		return nil
+	}
+	if _, isLocal := p.template.set.filters[nameToken.Val]; isLocal {
+		return nil
+	}
+
+	filtersMutex.RLock()
+	argument := filterArguments[nameToken.Val]
+	filtersMutex.RUnlock()
+
+	switch argument {
+	case filterArgumentRequired:
+		if !hasArgument {
+			return p.Error(fmt.Sprintf("Filter '%s' requires an argument.", nameToken.Val), nameToken)
+		}
+	case filterArgumentNone:
+		if hasArgument {
+			return p.Error(fmt.Sprintf("Filter '%s' does not take an argument.", nameToken.Val), nameToken)
+		}
+	}
+	return nil
+}
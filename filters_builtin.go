@@ -12,14 +12,12 @@ package pongo2
    ----------------------------
 
    get_static_prefix (reason: web-framework specific)
-   pprint (reason: python-specific)
    static (reason: web-framework specific)
 
    Reconsideration (not implemented yet):
    --------------------------------------
 
-   force_escape (reason: not yet needed since this is the behaviour of pongo2's escape filter)
-   safeseq (reason: same reason as `force_escape`)
+   safeseq (reason: not yet needed since escaping is applied on the final output)
    unordered_list (python-specific; not sure whether needed or not)
    dictsort (python-specific; maybe one could add a filter to sort a list of structs by a specific field name)
    dictsortreversed (see dictsort)
@@ -27,13 +25,19 @@ package pongo2
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
+	"html"
 	"math/rand"
 	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"errors"
@@ -46,21 +50,43 @@ func init() {
 	RegisterFilter("e", filterEscape)	// alias of `escape`
 	RegisterFilter("safe", filterSafe)
 	RegisterFilter("escapejs", filterEscapejs)
+	RegisterFilter("escapejson", filterEscapejson)
+	RegisterFilter("escape_once", filterEscapeOnce)
+	RegisterFilter("force_escape", filterForceEscape)
 
 	RegisterFilter("add", filterAdd)
 	RegisterFilter("addslashes", filterAddslashes)
+	RegisterFilter("apnumber", filterApnumber)
+	RegisterFilter("attr", filterAttr)
+	RegisterFilter("base62decode", filterBase62decode)
+	RegisterFilter("base62encode", filterBase62encode)
+	RegisterFilter("bool", filterBool)
 	RegisterFilter("capfirst", filterCapfirst)
 	RegisterFilter("center", filterCenter)
+	RegisterFilter("coalesce", filterCoalesce)
+	RegisterFilter("columns", filterColumns)
+	RegisterFilter("contains", filterContains)
 	RegisterFilter("cut", filterCut)
 	RegisterFilter("date", filterDate)
 	RegisterFilter("default", filterDefault)
+	RegisterFilter("default_if_error", filterDefaultIfError)
 	RegisterFilter("default_if_none", filterDefaultIfNone)
 	RegisterFilter("divisibleby", filterDivisibleby)
+	RegisterFilter("dump", filterDump)
+	RegisterFilter("endswith", filterEndswith)
 	RegisterFilter("first", filterFirst)
 	RegisterFilter("floatformat", filterFloatformat)
 	RegisterFilter("get_digit", filterGetdigit)
+	RegisterFilter("highlight", filterHighlight)
+	RegisterFilter("int", filterInt)
+	RegisterFilter("intcomma", filterIntcomma)
+	RegisterFilter("intword", filterIntword)
 	RegisterFilter("iriencode", filterIriencode)
+	RegisterFilter("items", filterItems)
 	RegisterFilter("join", filterJoin)
+	RegisterFilterKwargs("join", filterJoinKwargs)
+	RegisterFilter("json_canonical", filterJSONCanonical)
+	RegisterFilter("keys", filterKeys)
 	RegisterFilter("last", filterLast)
 	RegisterFilter("length", filterLength)
 	RegisterFilter("length_is", filterLengthis)
@@ -68,33 +94,79 @@ func init() {
 	RegisterFilter("linebreaksbr", filterLinebreaksbr)
 	RegisterFilter("linenumbers", filterLinenumbers)
 	RegisterFilter("ljust", filterLjust)
+	RegisterFilterWithContext("localtime", filterLocaltime)
+	RegisterFilter("lookup", filterLookup)
 	RegisterFilter("lower", filterLower)
 	RegisterFilter("make_list", filterMakelist)
+	RegisterFilter("mask", filterMask)
+	RegisterFilter("naturaltime", filterNaturaltime)
+	RegisterFilter("numberformat", filterNumberformat)
+	RegisterFilter("ordinal", filterOrdinal)
+	RegisterFilter("percentage", filterPercentage)
 	RegisterFilter("phone2numeric", filterPhone2numeric)
+	RegisterFilter("pluck", filterPluck)
 	RegisterFilter("pluralize", filterPluralize)
+	RegisterFilter("pprint", filterPprint)
 	RegisterFilter("random", filterRandom)
 	RegisterFilter("removetags", filterRemovetags)
+	RegisterFilterWithContext("render", filterRender)
+	RegisterFilter("replace", filterReplace)
+	RegisterFilterKwargs("replace", filterReplaceKwargs)
+	RegisterFilter("reverse", filterReverse)
 	RegisterFilter("rjust", filterRjust)
 	RegisterFilter("slice", filterSlice)
+	RegisterFilter("sort", filterSort)
+	RegisterFilter("sort_reversed", filterSortReversed)
 	RegisterFilter("split", filterSplit)
+	RegisterFilter("startswith", filterStartswith)
+	RegisterFilter("string", filterString)
 	RegisterFilter("stringformat", filterStringformat)
 	RegisterFilter("striptags", filterStriptags)
 	RegisterFilter("time", filterDate) // time uses filterDate (same golang-format)
+	RegisterFilter("timefmt", filterTimefmt)
 	RegisterFilter("title", filterTitle)
+	RegisterFilter("tojson", filterTojson)
+	RegisterFilter("truncate", filterTruncate)
+	RegisterFilterKwargs("truncate", filterTruncateKwargs)
+	RegisterFilter("truncate_middle", filterTruncateMiddle)
+	RegisterFilter("truncate_sentences", filterTruncateSentences)
 	RegisterFilter("truncatechars", filterTruncatechars)
 	RegisterFilter("truncatechars_html", filterTruncatecharsHTML)
 	RegisterFilter("truncatewords", filterTruncatewords)
 	RegisterFilter("truncatewords_html", filterTruncatewordsHTML)
+	RegisterFilter("tz", filterTz)
+	RegisterFilter("unescape", filterUnescape)
 	RegisterFilter("upper", filterUpper)
 	RegisterFilter("urlencode", filterUrlencode)
 	RegisterFilter("urlize", filterUrlize)
 	RegisterFilter("urlizetrunc", filterUrlizetrunc)
+	RegisterFilter("values", filterValues)
 	RegisterFilter("wordcount", filterWordcount)
 	RegisterFilter("wordwrap", filterWordwrap)
 	RegisterFilter("yesno", filterYesno)
+	RegisterFilter("zip", filterZip)
 
 	RegisterFilter("float", filterFloat)     // pongo-specific
 	RegisterFilter("integer", filterInteger) // pongo-specific
+
+	// Arguments of the built-in filters (see Options.StrictFilterArguments);
+	// all others take an optional argument
+	for _, name := range []string{"add", "attr", "center", "coalesce", "columns", "contains", "cut", "date",
+		"default", "default_if_error", "default_if_none", "divisibleby", "endswith", "get_digit", "highlight",
+		"length_is", "ljust", "lookup", "mask", "pluck", "removetags",
+		"replace", "rjust", "slice", "split", "startswith", "stringformat", "time", "timefmt", "truncate", "truncate_middle",
+		"truncate_sentences", "truncatechars", "truncatechars_html", "truncatewords", "truncatewords_html", "tz",
+		"urlizetrunc", "wordwrap", "zip"} {
+		filterArguments[name] = filterArgumentRequired
+	}
+	for _, name := range []string{"escape", "e", "safe", "escapejs", "escape_once", "force_escape",
+		"addslashes", "capfirst", "first", "iriencode", "items", "keys", "last", "length",
+		"linebreaks", "linebreaksbr", "linenumbers", "lower", "make_list", "phone2numeric",
+		"pprint", "random", "render", "reverse", "striptags", "title", "tojson", "json_canonical", "unescape", "upper", "values",
+		"wordcount", "bool", "float", "string", "integer", "intcomma", "intword", "ordinal", "base62encode", "base62decode",
+		"apnumber"} {
+		filterArguments[name] = filterArgumentNone
+	}
 }
 
 func filterTruncatecharsHelper(s string, newLen int) string {
@@ -109,6 +181,12 @@ func filterTruncatecharsHelper(s string, newLen int) string {
 	return string(runes)
 }
 
+// htmlVoidElements are the HTML elements without content and close tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
 func filterTruncateHTMLHelper(value string, newOutput *bytes.Buffer, cond func() bool, fn func(c rune, s int, idx int) int, finalize func()) {
 	vLen := len(value)
 	var tagStack []string
@@ -171,6 +249,7 @@ func filterTruncateHTMLHelper(value string, newOutput *bytes.Buffer, cond func()
 					tag := ""
 
 					params := false
+					selfClosing := false
 					for idx < vLen {
 						c2, size2 := utf8.DecodeRuneInString(value[idx:])
 						if c2 == utf8.RuneError {
@@ -185,9 +264,10 @@ func filterTruncateHTMLHelper(value string, newOutput *bytes.Buffer, cond func()
 							idx++ // consume ">"
 							break
 						}
+						selfClosing = c2 == '/'
 
 						if !params {
-							if c2 == ' ' {
+							if c2 == ' ' || c2 == '\t' || c2 == '\n' || c2 == '/' {
 								params = true
 							} else {
 								tag += string(c2)
@@ -197,8 +277,11 @@ func filterTruncateHTMLHelper(value string, newOutput *bytes.Buffer, cond func()
 						idx += size2
 					}
 
-					// Add tag to stack
-					tagStack = append(tagStack, tag)
+					// Add tag to stack (void elements like <br> have no
+					// close tag)
+					if !selfClosing && !htmlVoidElements[strings.ToLower(tag)] {
+						tagStack = append(tagStack, tag)
+					}
 				}
 			}
 		} else {
@@ -215,6 +298,180 @@ func filterTruncateHTMLHelper(value string, newOutput *bytes.Buffer, cond func()
 	}
 }
 
+// filterTruncate works like Jinja's truncate filter; the arguments are
+// length[:killwords[:end]] (like {{ text|truncate:50:true:" [more]" }}).
+// Without killwords the text is cut at the last word boundary.
+func filterTruncate(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 3 {
+		return nil, &Error{
+			Sender:    "filter:truncate",
+			OrigError: fmt.Errorf("expected length[:killwords[:end]] (got %d arguments)", len(params)),
+		}
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(params[0].String()))
+	if err != nil || length < 0 {
+		return nil, &Error{
+			Sender:    "filter:truncate",
+			OrigError: fmt.Errorf("length must be a non-negative integer (got: '%s')", params[0].String()),
+		}
+	}
+	killwords := len(params) > 1 && params[1].IsTrue()
+	end := "..."
+	if len(params) > 2 {
+		end = params[2].String()
+	}
+	return filterTruncateHelper(in, length, killwords, end), nil
+}
+
+// filterTruncateKwargs is the keyword arguments form of truncate:
+// truncate(length=50, killwords=true, end="…")
+func filterTruncateKwargs(in *Value, kwargs map[string]*Value) (*Value, *Error) {
+	length, killwords, end := -1, false, "..."
+	for name, value := range kwargs {
+		switch name {
+		case "length":
+			if !value.IsInteger() {
+				return nil, &Error{
+					Sender:    "filter:truncate",
+					OrigError: fmt.Errorf("length must be a non-negative integer (got: '%s')", value.String()),
+				}
+			}
+			length = value.Integer()
+		case "killwords":
+			killwords = value.IsTrue()
+		case "end":
+			end = value.String()
+		default:
+			return nil, &Error{
+				Sender:    "filter:truncate",
+				OrigError: fmt.Errorf("unknown keyword argument '%s'", name),
+			}
+		}
+	}
+	if length < 0 {
+		return nil, &Error{
+			Sender:    "filter:truncate",
+			OrigError: errors.New("length must be a non-negative integer"),
+		}
+	}
+	return filterTruncateHelper(in, length, killwords, end), nil
+}
+
+func filterTruncateHelper(in *Value, length int, killwords bool, end string) *Value {
+	runes := []rune(in.String())
+	if len(runes) <= length {
+		return in
+	}
+
+	cut := max(length-utf8.RuneCountInString(end), 0)
+	result := string(runes[:cut])
+	if !killwords {
+		if idx := strings.LastIndexAny(result, tokenSpaceChars); idx >= 0 {
+			result = result[:idx]
+		}
+	}
+	return AsValue(result + end)
+}
+
+// filterTruncateMiddle keeps the start and the end of the input and replaces
+// the middle by an ellipsis; the arguments are length[:ellipsis].
+func filterTruncateMiddle(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return nil, &Error{
+			Sender:    "filter:truncate_middle",
+			OrigError: fmt.Errorf("expected length[:ellipsis] (got %d arguments)", len(params)),
+		}
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(params[0].String()))
+	if err != nil || length < 0 {
+		return nil, &Error{
+			Sender:    "filter:truncate_middle",
+			OrigError: fmt.Errorf("length must be a non-negative integer (got: '%s')", params[0].String()),
+		}
+	}
+	ellipsis := []rune("...")
+	if len(params) > 1 {
+		ellipsis = []rune(params[1].String())
+	}
+
+	runes := []rune(in.String())
+	if len(runes) <= length {
+		return in, nil
+	}
+
+	keep := length - len(ellipsis)
+	if keep <= 0 {
+		return AsValue(string(ellipsis[:min(length, len(ellipsis))])), nil
+	}
+	head := (keep + 1) / 2
+	tail := keep - head
+	return AsValue(string(runes[:head]) + string(ellipsis) + string(runes[len(runes)-tail:])), nil
+}
+
+// filterTruncateSentencesAbbreviations are the words (lowercase, without the
+// period) whose period doesn't end a sentence.
+var filterTruncateSentencesAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true, "st": true, "vs": true,
+}
+
+// filterTruncateSentences keeps the first n sentences of the input and adds
+// " ..." if there are more. A sentence ends with ".", "!" or "?" (possibly
+// followed by closing quotes or brackets) followed by whitespace or the end
+// of the text. Abbreviations are handled conservatively: a single period
+// doesn't end a sentence after a single letter ("J. Doe"), a word containing
+// periods ("e.g.") or a title like "Dr.".
+func filterTruncateSentences(in *Value, param *Value) (*Value, *Error) {
+	s := in.String()
+	n := param.Integer()
+	if n <= 0 {
+		return AsValue(""), nil
+	}
+
+	sentences := 0
+	for i := 0; i < len(s); i++ {
+		if !strings.ContainsRune(".!?", rune(s[i])) {
+			continue
+		}
+		end := i + 1
+		for end < len(s) && strings.ContainsRune(".!?", rune(s[end])) {
+			end++
+		}
+		for end < len(s) && strings.ContainsRune("\"')]", rune(s[end])) {
+			end++
+		}
+		if end < len(s) && !strings.ContainsRune(tokenSpaceChars, rune(s[end])) {
+			i = end - 1
+			continue
+		}
+		if s[i] == '.' && end == i+1 && isAbbreviation(s[:i]) {
+			continue
+		}
+
+		sentences++
+		if sentences == n {
+			if strings.TrimSpace(s[end:]) == "" {
+				return in, nil
+			}
+			return AsValue(s[:end] + " ..."), nil
+		}
+		i = end - 1
+	}
+	return in, nil
+}
+
+// isAbbreviation reports whether the last word of s is an abbreviation (see
+// filterTruncateSentences) if it's followed by a period.
+func isAbbreviation(s string) bool {
+	word := strings.TrimLeft(s[strings.LastIndexAny(s, tokenSpaceChars)+1:], "\"'([")
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return unicode.IsLetter(r)
+	}
+	return strings.Contains(word, ".") || filterTruncateSentencesAbbreviations[strings.ToLower(word)]
+}
+
 func filterTruncatechars(in *Value, param *Value) (*Value, *Error) {
 	s := in.String()
 	newLen := param.Integer()
@@ -324,6 +581,69 @@ func filterEscape(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(output), nil
 }
 
+func filterForceEscape(in *Value, param *Value) (*Value, *Error) {
+	output, err := filterEscape(in, param)
+	if err != nil {
+		return nil, err
+	}
+	return AsSafeValue(output.String()), nil
+}
+
+var filterEscapeOnceEntityRegexp = regexp.MustCompile(`&(?:[a-zA-Z][a-zA-Z0-9]*|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+
+func filterEscapeOnce(in *Value, param *Value) (*Value, *Error) {
+	sin := in.String()
+
+	var b bytes.Buffer
+	last := 0
+	for _, loc := range filterEscapeOnceEntityRegexp.FindAllStringIndex(sin, -1) {
+		escaped, _ := filterEscape(AsValue(sin[last:loc[0]]), nil)
+		b.WriteString(escaped.String())
+		b.WriteString(sin[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	escaped, _ := filterEscape(AsValue(sin[last:]), nil)
+	b.WriteString(escaped.String())
+
+	return AsSafeValue(b.String()), nil
+}
+
+// filterHighlight escapes the input and wraps all (case-insensitive)
+// occurrences of the query's space-separated terms in <mark>-tags.
+// Overlapping and adjacent matches are merged into one mark.
+func filterHighlight(in *Value, param *Value) (*Value, *Error) {
+	sin := in.String()
+
+	// Collect the matched ranges of all terms
+	var ranges [][]int
+	for _, term := range strings.Fields(param.String()) {
+		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(term))
+		ranges = append(ranges, re.FindAllStringIndex(sin, -1)...)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	var b bytes.Buffer
+	last := 0 // end of the last mark
+	for i := 0; i < len(ranges); {
+		start, end := ranges[i][0], ranges[i][1]
+		for i++; i < len(ranges) && ranges[i][0] <= end; i++ {
+			end = max(end, ranges[i][1])
+		}
+		b.WriteString(html.EscapeString(sin[last:start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(sin[start:end]))
+		b.WriteString("</mark>")
+		last = end
+	}
+	b.WriteString(html.EscapeString(sin[last:]))
+
+	return AsSafeValue(b.String()), nil
+}
+
+func filterUnescape(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(html.UnescapeString(in.String())), nil
+}
+
 func filterSafe(in *Value, param *Value) (*Value, *Error) {
 	return in, nil // nothing to do here, just to keep track of the safe application
 }
@@ -377,6 +697,91 @@ func filterEscapejs(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(b.String()), nil
 }
 
+// filterEscapejson JSON-encodes the value for the use within an HTML
+// attribute. The optional argument selects the quoting of the attribute
+// ("double" (default) or "single"); the matching quote gets replaced by its
+// HTML entity.
+func filterEscapejson(in *Value, param *Value) (*Value, *Error) {
+	quote := "double"
+	if !param.IsNil() && param.String() != "" {
+		quote = param.String()
+	}
+
+	var replacer *strings.Replacer
+	switch quote {
+	case "double":
+		replacer = strings.NewReplacer(`"`, "&quot;")
+	case "single":
+		replacer = strings.NewReplacer("'", "&#39;")
+	default:
+		return nil, &Error{
+			Sender:    "filter:escapejson",
+			OrigError: fmt.Errorf("unknown quoting '%s' (must be 'double' or 'single')", quote),
+		}
+	}
+
+	b, err := filterMarshalJSON("escapejson", in)
+	if err != nil {
+		return nil, err
+	}
+
+	return AsSafeValue(replacer.Replace(b)), nil
+}
+
+// filterMarshalJSON encodes the value using encoding/json, so custom
+// json.Marshaler implementations are respected (times are encoded as
+// RFC3339). json.Marshal already escapes <, > and & within strings.
+func filterMarshalJSON(name string, in *Value) (string, *Error) {
+	b, err := json.Marshal(in.Interface())
+	if err != nil {
+		return "", &Error{
+			Sender:    "filter:" + name,
+			OrigError: err,
+		}
+	}
+	return string(b), nil
+}
+
+// filterTojson encodes the value as JSON for the use within a <script> or
+// a (quoted) HTML attribute; single quotes get escaped as well.
+func filterTojson(in *Value, param *Value) (*Value, *Error) {
+	b, err := filterMarshalJSON("tojson", in)
+	if err != nil {
+		return nil, err
+	}
+	return AsSafeValue(strings.Replace(b, "'", `\u0027`, -1)), nil
+}
+
+// filterJSONCanonical encodes the value as canonical JSON (for cache keys or
+// signatures): the keys of all objects are sorted, this includes the fields
+// of structs (which encoding/json encodes in declaration order), and there's
+// no insignificant whitespace. Numbers are kept as they are encoded.
+func filterJSONCanonical(in *Value, param *Value) (*Value, *Error) {
+	b, err := filterMarshalJSON("json_canonical", in)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoded into maps, all keys get sorted while encoding it again
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, &Error{
+			Sender:    "filter:json_canonical",
+			OrigError: err,
+		}
+	}
+	canonical, jsonErr := json.Marshal(data)
+	if jsonErr != nil {
+		return nil, &Error{
+			Sender:    "filter:json_canonical",
+			OrigError: jsonErr,
+		}
+	}
+	return AsValue(string(canonical)), nil
+}
+
 func filterAdd(in *Value, param *Value) (*Value, *Error) {
 	if in.IsNumber() && param.IsNumber() {
 		if in.IsFloat() || param.IsFloat() {
@@ -396,6 +801,295 @@ func filterAddslashes(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(output), nil
 }
 
+func filterAttr(in *Value, param *Value) (*Value, *Error) {
+	out, err := resolveValuePath(in, param.String())
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:attr",
+			OrigError: err,
+		}
+	}
+	return out, nil
+}
+
+// filterLookup returns the item of the map (or list) given as param for the
+// value as key (or index): {{ status_code|lookup:status_names }}. Keys are
+// converted to the key type of the map if possible. Missing items are nil.
+func filterLookup(in *Value, param *Value) (*Value, *Error) {
+	container := param.getResolvedValue()
+	switch container.Kind() {
+	case reflect.Map:
+		keyType := container.Type().Key()
+		var key reflect.Value
+		switch keyType.Kind() {
+		case reflect.String:
+			key = reflect.ValueOf(in.String()).Convert(keyType)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			i, ok := lookupIndex(in)
+			if !ok {
+				return AsValue(nil), nil
+			}
+			key = reflect.ValueOf(i).Convert(keyType)
+		default:
+			if !in.getResolvedValue().IsValid() || !in.getResolvedValue().Type().ConvertibleTo(keyType) {
+				return AsValue(nil), nil
+			}
+			key = in.getResolvedValue().Convert(keyType)
+		}
+		item := container.MapIndex(key)
+		if !item.IsValid() {
+			return AsValue(nil), nil
+		}
+		return AsValue(item.Interface()), nil
+	case reflect.Slice, reflect.Array:
+		i, ok := lookupIndex(in)
+		if !ok || i < 0 || i >= container.Len() {
+			return AsValue(nil), nil
+		}
+		return AsValue(container.Index(i).Interface()), nil
+	}
+	return nil, &Error{
+		Sender:    "filter:lookup",
+		OrigError: fmt.Errorf("the argument must be a map or a list (got: %s)", container.Kind()),
+	}
+}
+
+// lookupIndex returns the value as integer key or index of lookup; strings
+// need to be decimal numbers (like "2").
+func lookupIndex(in *Value) (int, bool) {
+	if in.IsInteger() {
+		return in.Integer(), true
+	}
+	i, err := strconv.Atoi(in.String())
+	return i, err == nil
+}
+
+// filterZip combines the items of two lists into pairs:
+// {% for key, value in keys|zip:values %} iterates them like a map (but in
+// order). If the lists have different lengths, the additional items of the
+// longer one are ignored.
+func filterZip(in *Value, param *Value) (*Value, *Error) {
+	for _, v := range []*Value{in, param} {
+		if kind := v.getResolvedValue().Kind(); kind != reflect.Slice && kind != reflect.Array {
+			return nil, &Error{
+				Sender:    "filter:zip",
+				OrigError: fmt.Errorf("the value and the argument must be lists (got: %s)", kind),
+			}
+		}
+	}
+
+	length := in.Len()
+	if param.Len() < length {
+		length = param.Len()
+	}
+	pairs := make(valuePairs, length)
+	for i := range pairs {
+		pairs[i] = [2]interface{}{in.Index(i).Interface(), param.Index(i).Interface()}
+	}
+	return AsValue(pairs), nil
+}
+
+// filterPluck returns the values of an attribute (a dotted path like attr
+// uses, e.g. "address.city") of all list items: {{ users|pluck:"email" }}.
+// Items missing the attribute are skipped, unless the second argument is
+// true ({{ users|pluck:"email":true }}): then nil is used for them.
+func filterPluck(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return nil, &Error{
+			Sender:    "filter:pluck",
+			OrigError: fmt.Errorf("expected path[:keep missing] (got %d arguments)", len(params)),
+		}
+	}
+	path := strings.TrimSpace(params[0].String())
+	keepMissing := len(params) > 1 && params[1].IsTrue()
+
+	switch in.getResolvedValue().Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return in, nil
+	}
+
+	values := make([]interface{}, 0, in.Len())
+	for i := 0; i < in.Len(); i++ {
+		out, err := resolveValuePath(in.Index(i), path)
+		if err != nil {
+			return nil, &Error{
+				Sender:    "filter:pluck",
+				OrigError: err,
+			}
+		}
+		if out.IsNil() {
+			if keepMissing {
+				values = append(values, nil)
+			}
+			continue
+		}
+		values = append(values, out.Interface())
+	}
+	return AsValue(values), nil
+}
+
+// filterSearchArguments returns the arguments of startswith, endswith and
+// contains: the string (or item) to search for and, optionally, whether to
+// ignore the case ({{ name|startswith:"a":true }}).
+func filterSearchArguments(name string, param *Value) (*Value, bool, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return nil, false, &Error{
+			Sender:    "filter:" + name,
+			OrigError: fmt.Errorf("expected search[:ignore case] (got %d arguments)", len(params)),
+		}
+	}
+	return params[0], len(params) > 1 && params[1].IsTrue(), nil
+}
+
+func filterStartswith(in *Value, param *Value) (*Value, *Error) {
+	search, ignoreCase, err := filterSearchArguments("startswith", param)
+	if err != nil {
+		return nil, err
+	}
+	if ignoreCase {
+		return AsValue(strings.HasPrefix(strings.ToLower(in.String()), strings.ToLower(search.String()))), nil
+	}
+	return AsValue(strings.HasPrefix(in.String(), search.String())), nil
+}
+
+func filterEndswith(in *Value, param *Value) (*Value, *Error) {
+	search, ignoreCase, err := filterSearchArguments("endswith", param)
+	if err != nil {
+		return nil, err
+	}
+	if ignoreCase {
+		return AsValue(strings.HasSuffix(strings.ToLower(in.String()), strings.ToLower(search.String()))), nil
+	}
+	return AsValue(strings.HasSuffix(in.String(), search.String())), nil
+}
+
+// filterContains checks whether a string contains a substring or a list
+// contains an item. Ignoring the case, list items are compared by their
+// string representation.
+func filterContains(in *Value, param *Value) (*Value, *Error) {
+	search, ignoreCase, err := filterSearchArguments("contains", param)
+	if err != nil {
+		return nil, err
+	}
+	switch in.getResolvedValue().Kind() {
+	case reflect.Slice, reflect.Array:
+		if !ignoreCase {
+			return AsValue(in.Contains(search)), nil
+		}
+		found := false
+		in.Iterate(func(idx, count int, item, _ *Value) bool {
+			found = strings.EqualFold(AsValue(item.Interface()).String(), search.String())
+			return !found
+		}, func() {})
+		return AsValue(found), nil
+	}
+	if ignoreCase {
+		return AsValue(strings.Contains(strings.ToLower(in.String()), strings.ToLower(search.String()))), nil
+	}
+	return AsValue(strings.Contains(in.String(), search.String())), nil
+}
+
+// filterDump renders the value as a (nested) HTML table for debugging; the
+// optional param limits the depth (default: 5) to protect against cycles.
+func filterDump(in *Value, param *Value) (*Value, *Error) {
+	depth := 5
+	if !param.IsNil() {
+		depth = param.Integer()
+	}
+	var b bytes.Buffer
+	filterDumpHelper(&b, in, depth)
+	return AsSafeValue(b.String()), nil
+}
+
+func filterDumpHelper(b *bytes.Buffer, v *Value, depth int) {
+	writeEscaped := func(s string) {
+		escaped, _ := filterEscape(AsValue(s), nil)
+		b.WriteString(escaped.String())
+	}
+	writeRow := func(key string, value *Value) {
+		b.WriteString("<tr><th>")
+		writeEscaped(key)
+		b.WriteString("</th><td>")
+		filterDumpHelper(b, value, depth-1)
+		b.WriteString("</td></tr>")
+	}
+
+	rv := v.getResolvedValue()
+	switch {
+	case v.IsNil():
+		b.WriteString("<em>nil</em>")
+		return
+	case rv.Kind() != reflect.Map && rv.Kind() != reflect.Struct && rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array,
+		rv.Kind() == reflect.Struct && v.isTextual():
+		writeEscaped(v.String())
+		return
+	case depth <= 0:
+		b.WriteString("<em>...</em>")
+		return
+	}
+
+	b.WriteString(`<table class="pongo2-dump">`)
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := sortedKeys(rv.MapKeys())
+		sort.Sort(keys)
+		for _, key := range keys {
+			writeRow(AsValue(key.Interface()).String(), AsValue(rv.MapIndex(key).Interface()))
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if field := rv.Type().Field(i); field.PkgPath == "" {
+				writeRow(field.Name, AsValue(rv.Field(i).Interface()))
+			}
+		}
+	default:
+		for i := 0; i < rv.Len(); i++ {
+			writeRow(strconv.Itoa(i), AsValue(rv.Index(i).Interface()))
+		}
+	}
+	b.WriteString("</table>")
+}
+
+// filterRenderMaxDepth limits the nesting of the render filter (snippets
+// rendering snippets) unless the set has a maximum include depth.
+const filterRenderMaxDepth = 10
+
+// filterRender parses the input as template (using the current template's
+// set) and renders it with the public context; the output is safe.
+func filterRender(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error) {
+	set := ctx.template.set
+	if set.disableRenderFilter {
+		return nil, &Error{
+			Sender:    "filter:render",
+			OrigError: errors.New("the render filter is disabled for this template set"),
+		}
+	}
+	maxDepth := filterRenderMaxDepth
+	if set.maxIncludeDepth > 0 {
+		maxDepth = set.maxIncludeDepth
+	}
+	if ctx.template.depth+1 > maxDepth {
+		return nil, &Error{
+			Sender:    "filter:render",
+			OrigError: fmt.Errorf("maximum render depth of %d exceeded", maxDepth),
+		}
+	}
+
+	tpl, err := newTemplate(set, "<string>", true, []byte(in.String()), ctx.template, "")
+	if err != nil {
+		return nil, err.(*Error)
+	}
+	var b bytes.Buffer
+	if err := tpl.executeIncluded(ctx, ctx.Public, &b); err != nil {
+		return nil, err.(*Error).addFrame("render")
+	}
+	return AsSafeValue(b.String()), nil
+}
+
 func filterCut(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.Replace(in.String(), param.String(), "", -1)), nil
 }
@@ -405,7 +1099,14 @@ func filterLength(in *Value, param *Value) (*Value, *Error) {
 }
 
 func filterLengthis(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(in.Len() == param.Integer()), nil
+	switch in.getResolvedValue().Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return AsValue(in.Len() == param.Integer()), nil
+	}
+	return nil, &Error{
+		Sender:    "filter:length_is",
+		OrigError: errors.New("filter input argument must be a string, slice, array or map"),
+	}
 }
 
 func filterDefault(in *Value, param *Value) (*Value, *Error) {
@@ -415,6 +1116,42 @@ func filterDefault(in *Value, param *Value) (*Value, *Error) {
 	return in, nil
 }
 
+// filterCoalesce returns the first non-empty value of the input and the
+// arguments, or the last argument if all of them are empty:
+//
+//	{{ nickname|coalesce:first_name:username:"Anonymous" }}
+//
+// Unlike default, only nil and empty strings, slices, arrays and maps count
+// as empty (0 and false don't).
+func filterCoalesce(in *Value, param *Value) (*Value, *Error) {
+	candidates := append([]*Value{in}, filterParameterList(param)...)
+	for _, candidate := range candidates[:len(candidates)-1] {
+		if !isCoalesceEmpty(candidate) {
+			return candidate, nil
+		}
+	}
+	return candidates[len(candidates)-1], nil
+}
+
+func isCoalesceEmpty(v *Value) bool {
+	if v.IsNil() {
+		return true
+	}
+	switch v.getResolvedValue().Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// filterDefaultIfError passes the value through unchanged; if the evaluation
+// of the preceding expression (or one of its filters) fails, the filter
+// pipeline uses the argument instead of the value:
+// {{ config.RiskyLoad|default_if_error:"{}" }}.
+func filterDefaultIfError(in *Value, param *Value) (*Value, *Error) {
+	return in, nil
+}
+
 func filterDefaultIfNone(in *Value, param *Value) (*Value, *Error) {
 	if in.IsNil() {
 		return param, nil
@@ -422,18 +1159,68 @@ func filterDefaultIfNone(in *Value, param *Value) (*Value, *Error) {
 	return in, nil
 }
 
+// divisiblebyInteger returns the integer represented by v; floats without
+// a fractional part and numeric strings are accepted as well.
+func divisiblebyInteger(v *Value) (int, bool) {
+	switch {
+	case v.IsInteger():
+		return v.Integer(), true
+	case v.IsFloat():
+		f := v.Float()
+		if f == float64(int(f)) {
+			return int(f), true
+		}
+	case v.IsString():
+		i, err := strconv.Atoi(strings.TrimSpace(v.String()))
+		return i, err == nil
+	}
+	return 0, false
+}
+
 func filterDivisibleby(in *Value, param *Value) (*Value, *Error) {
-	if param.Integer() == 0 {
-		return AsValue(false), nil
+	dividend, ok1 := divisiblebyInteger(in)
+	divisor, ok2 := divisiblebyInteger(param)
+	if !ok1 || !ok2 {
+		return nil, &Error{
+			Sender:    "filter:divisibleby",
+			OrigError: errors.New("filter input and argument must be integers"),
+		}
+	}
+	if divisor == 0 {
+		return nil, &Error{
+			Sender:    "filter:divisibleby",
+			OrigError: errors.New("divisor must not be zero"),
+		}
 	}
-	return AsValue(in.Integer()%param.Integer() == 0), nil
+	return AsValue(dividend%divisor == 0), nil
 }
 
 func filterFirst(in *Value, param *Value) (*Value, *Error) {
+	return firstOrLast(in, false), nil
+}
+
+// firstOrLast returns the first (or last) item of a slice/array, rune of a
+// string or value of a map. Maps are ordered by their sorted keys to get a
+// deterministic result. Empty or unsupported inputs return nil.
+func firstOrLast(in *Value, last bool) *Value {
+	if in.getResolvedValue().Kind() == reflect.Map {
+		keys, _ := filterMapKeys("", in)
+		if len(keys) == 0 {
+			return AsValue(nil)
+		}
+		key := keys[0]
+		if last {
+			key = keys[len(keys)-1]
+		}
+		return AsValue(in.getResolvedValue().MapIndex(key).Interface())
+	}
 	if in.CanSlice() && in.Len() > 0 {
-		return in.Index(0), nil
+		if last {
+			return in.Index(in.Len() - 1)
+		}
+		return in.Index(0)
 	}
-	return AsValue(""), nil
+	return AsValue(nil)
 }
 
 func filterFloatformat(in *Value, param *Value) (*Value, *Error) {
@@ -467,12 +1254,31 @@ func filterFloatformat(in *Value, param *Value) (*Value, *Error) {
 }
 
 func filterGetdigit(in *Value, param *Value) (*Value, *Error) {
+	// Only integers (or strings containing an integer) have digits
+	var number int
+	switch {
+	case in.IsInteger():
+		number = in.Integer()
+	case in.IsString():
+		n, err := strconv.Atoi(in.String())
+		if err != nil {
+			return in, nil
+		}
+		number = n
+	default:
+		return in, nil
+	}
+	if number < 0 {
+		number = -number
+	}
+
+	digits := strconv.Itoa(number)
 	i := param.Integer()
-	l := len(in.String()) // do NOT use in.Len() here!
+	l := len(digits)
 	if i <= 0 || i > l {
 		return in, nil
 	}
-	return AsValue(in.String()[l-i] - 48), nil
+	return AsValue(int(digits[l-i] - '0')), nil
 }
 
 const filterIRIChars = "/#%[]=:;$&()+,!?*@'~"
@@ -492,23 +1298,215 @@ func filterIriencode(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(b.String()), nil
 }
 
+// filterMapKeys returns the keys of a map input sorted in ascending order
+// (see sortedKeys), so the map filters produce deterministic output.
+func filterMapKeys(name string, in *Value) (sortedKeys, *Error) {
+	if in.getResolvedValue().Kind() != reflect.Map {
+		return nil, &Error{
+			Sender:    "filter:" + name,
+			OrigError: errors.New("filter input argument must be a map"),
+		}
+	}
+	keys := sortedKeys(in.getResolvedValue().MapKeys())
+	sort.Sort(keys)
+	return keys, nil
+}
+
+func filterItems(in *Value, param *Value) (*Value, *Error) {
+	keys, err := filterMapKeys("items", in)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, map[string]interface{}{
+			"key":   key.Interface(),
+			"value": in.getResolvedValue().MapIndex(key).Interface(),
+		})
+	}
+	return AsValue(items), nil
+}
+
+func filterKeys(in *Value, param *Value) (*Value, *Error) {
+	keys, err := filterMapKeys("keys", in)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.Interface())
+	}
+	return AsValue(result), nil
+}
+
+func filterValues(in *Value, param *Value) (*Value, *Error) {
+	keys, err := filterMapKeys("values", in)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, in.getResolvedValue().MapIndex(key).Interface())
+	}
+	return AsValue(result), nil
+}
+
+// compareSortValues orders values by their class first (numbers, strings,
+// times, everything else), so the order of mixed lists doesn't depend on the
+// input order. Within a class the values are compared using Value.CompareTo
+// or, if they're incomparable, by their string representations.
+func compareSortValues(a, b *Value) int {
+	if ca, cb := sortValueClass(a), sortValueClass(b); ca != cb {
+		return compareOrdered(ca < cb, ca > cb)
+	}
+	if cmp, err := a.CompareTo(b); err == nil {
+		return cmp
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+func sortValueClass(v *Value) int {
+	switch {
+	case v.IsNumber():
+		return 0
+	case v.IsString():
+		return 1
+	case v.IsTime():
+		return 2
+	}
+	return 3
+}
+
+func sortList(name string, in *Value, param *Value, reversed bool) (*Value, *Error) {
+	rv := in.getResolvedValue()
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, &Error{
+			Sender:    "filter:" + name,
+			OrigError: errors.New("filter input argument must be a slice or array"),
+		}
+	}
+
+	items := make([]interface{}, 0, rv.Len())
+	keys := make([]*Value, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i).Interface()
+		key, err := resolveValuePath(AsValue(item), param.String())
+		if err != nil {
+			return nil, &Error{
+				Sender:    "filter:" + name,
+				OrigError: err,
+			}
+		}
+		items = append(items, item)
+		keys = append(keys, key)
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		cmp := compareSortValues(keys[order[i]], keys[order[j]])
+		if reversed {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	result := make([]interface{}, 0, len(items))
+	for _, i := range order {
+		result = append(result, items[i])
+	}
+	return AsValue(result), nil
+}
+
+func filterSort(in *Value, param *Value) (*Value, *Error) {
+	return sortList("sort", in, param, false)
+}
+
+func filterSortReversed(in *Value, param *Value) (*Value, *Error) {
+	return sortList("sort_reversed", in, param, true)
+}
+
+func filterReverse(in *Value, param *Value) (*Value, *Error) {
+	rv := in.getResolvedValue()
+	switch rv.Kind() {
+	case reflect.String:
+		runes := []rune(rv.String())
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return AsValue(string(runes)), nil
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, 0, rv.Len())
+		for i := rv.Len() - 1; i >= 0; i-- {
+			result = append(result, rv.Index(i).Interface())
+		}
+		return AsValue(result), nil
+	}
+	return nil, &Error{
+		Sender:    "filter:reverse",
+		OrigError: errors.New("filter input argument must be a string, slice or array"),
+	}
+}
+
+// filterJoin joins the items of a list with the separator; a second argument
+// is the attribute (path) of the items to join: {{ authors|join:", ":"name" }}
 func filterJoin(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return nil, &Error{
+			Sender:    "filter:join",
+			OrigError: fmt.Errorf("expected separator[:attribute] (got %d arguments)", len(params)),
+		}
+	}
+	attr := ""
+	if len(params) > 1 {
+		attr = params[1].String()
+	}
+	return filterJoinHelper(in, params[0].String(), attr)
+}
+
+// filterJoinKwargs is the keyword arguments form of join which can join an
+// attribute (path) of the items: join(sep=", ", attr="author.name")
+func filterJoinKwargs(in *Value, kwargs map[string]*Value) (*Value, *Error) {
+	sep, attr := "", ""
+	for name, value := range kwargs {
+		switch name {
+		case "sep":
+			sep = value.String()
+		case "attr":
+			attr = value.String()
+		default:
+			return nil, &Error{
+				Sender:    "filter:join",
+				OrigError: fmt.Errorf("unknown keyword argument '%s'", name),
+			}
+		}
+	}
+	return filterJoinHelper(in, sep, attr)
+}
+
+func filterJoinHelper(in *Value, sep, attr string) (*Value, *Error) {
 	if !in.CanSlice() {
 		return in, nil
 	}
-	sep := param.String()
 	sl := make([]string, 0, in.Len())
 	for i := 0; i < in.Len(); i++ {
-		sl = append(sl, in.Index(i).String())
+		item, err := resolveValuePath(in.Index(i), attr)
+		if err != nil {
+			return nil, &Error{
+				Sender:    "filter:join",
+				OrigError: err,
+			}
+		}
+		sl = append(sl, item.String())
 	}
 	return AsValue(strings.Join(sl, sep)), nil
 }
 
 func filterLast(in *Value, param *Value) (*Value, *Error) {
-	if in.CanSlice() && in.Len() > 0 {
-		return in.Index(in.Len() - 1), nil
-	}
-	return AsValue(""), nil
+	return firstOrLast(in, true), nil
 }
 
 func filterUpper(in *Value, param *Value) (*Value, *Error) {
@@ -537,40 +1535,823 @@ func filterCapfirst(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.ToUpper(string(r)) + t[size:]), nil
 }
 
+// filterPadding parses the arguments of the padding filters (center, ljust
+// and rjust): width[:fill] with a single fill character (default: space),
+// like {{ "ab"|center:6:"*" }}. It returns the number of fill characters
+// needed.
+func filterPadding(name string, in *Value, param *Value) (int, string, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return 0, "", &Error{
+			Sender:    "filter:" + name,
+			OrigError: fmt.Errorf("expected width[:fill] (got %d arguments)", len(params)),
+		}
+	}
+	width, fill := params[0].Integer(), " "
+	if !params[0].IsNumber() {
+		width, _ = strconv.Atoi(strings.TrimSpace(params[0].String()))
+	}
+	if len(params) > 1 {
+		fill = params[1].String()
+	}
+	if utf8.RuneCountInString(fill) != 1 {
+		return 0, "", &Error{
+			Sender:    "filter:" + name,
+			OrigError: fmt.Errorf("fill must be a single character (got: '%s')", fill),
+		}
+	}
+	return max(width-utf8.RuneCountInString(in.String()), 0), fill, nil
+}
+
 func filterCenter(in *Value, param *Value) (*Value, *Error) {
-	width := param.Integer()
-	slen := in.Len()
-	if width <= slen {
+	padding, fill, err := filterPadding("center", in, param)
+	if err != nil {
+		return nil, err
+	}
+	if padding == 0 {
 		return in, nil
 	}
 
-	spaces := width - slen
-	left := spaces/2 + spaces%2
-	right := spaces / 2
+	left := padding/2 + padding%2
+	right := padding / 2
+
+	return AsValue(fmt.Sprintf("%s%s%s", strings.Repeat(fill, left),
+		in.String(), strings.Repeat(fill, right))), nil
+}
 
-	return AsValue(fmt.Sprintf("%s%s%s", strings.Repeat(" ", left),
-		in.String(), strings.Repeat(" ", right))), nil
+// filterLocaltimeKey is the context key the localtime filter reads the
+// timezone from by default.
+const filterLocaltimeKey = "timezone"
+
+// filterLocaltime converts a time (anything the date filter takes) to the
+// timezone of the public context for further formatting:
+// {{ event_at|localtime|date:"15:04" }}. The timezone is a name (like
+// "Europe/Berlin") or a *time.Location under the key "timezone"; the first
+// argument names another key (localtime:"user_tz"). Missing or invalid
+// timezones fall back to UTC, unless true is given as second argument (then
+// they're errors): localtime:"user_tz":true or localtime:"":true.
+func filterLocaltime(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error) {
+	keyValue, strict, err := filterTimezoneOptions("filter:localtime", param)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(keyValue.String())
+	if key == "" {
+		key = filterLocaltimeKey
+	}
+	zone, has := ctx.Public[key]
+	if !has || zone == nil {
+		if strict {
+			return nil, &Error{
+				Sender:    "filter:localtime",
+				OrigError: fmt.Errorf("no timezone in the context (key '%s')", key),
+			}
+		}
+		zone = time.UTC
+	}
+	return filterConvertTimezone("filter:localtime", in, zone, strict)
+}
+
+// filterTz converts a time to the timezone given as first argument (a name
+// or a *time.Location), like localtime does: tz:"America/New_York" or
+// tz:"America/New_York":true.
+func filterTz(in *Value, param *Value) (*Value, *Error) {
+	zone, strict, err := filterTimezoneOptions("filter:tz", param)
+	if err != nil {
+		return nil, err
+	}
+	if loc, ok := zone.Interface().(*time.Location); ok && loc != nil {
+		return filterConvertTimezone("filter:tz", in, loc, strict)
+	}
+	return filterConvertTimezone("filter:tz", in, strings.TrimSpace(zone.String()), strict)
+}
+
+// filterTimezoneOptions splits the arguments of the timezone filters into
+// the timezone (or key) and the strict flag.
+func filterTimezoneOptions(sender string, param *Value) (*Value, bool, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return nil, false, &Error{
+			Sender:    sender,
+			OrigError: fmt.Errorf("expected zone[:strict] (got %d arguments)", len(params)),
+		}
+	}
+	return params[0], len(params) > 1 && params[1].IsTrue(), nil
 }
 
+// filterConvertTimezone converts the input of the timezone filters to the
+// zone (a *time.Location or a timezone name); invalid zones are UTC unless
+// strict is set.
+func filterConvertTimezone(sender string, in *Value, zone interface{}, strict bool) (*Value, *Error) {
+	t, err := filterDateInput(sender, in)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	switch z := zone.(type) {
+	case *time.Location:
+		if z != nil {
+			loc = z
+		}
+	default:
+		name := fmt.Sprintf("%v", z)
+		l, lerr := time.LoadLocation(name)
+		if lerr != nil || name == "" {
+			if strict {
+				return nil, &Error{
+					Sender:    sender,
+					OrigError: fmt.Errorf("unknown timezone '%s'", name),
+				}
+			}
+			break
+		}
+		loc = l
+	}
+	return AsValue(t.In(loc)), nil
+}
+
+// Layouts the date (and time) filter tries to parse strings with
+var filterDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// filterDate formats a time.Time; integers are treated as Unix timestamps
+// (seconds, in UTC) and strings are parsed using filterDateLayouts. Layouts
+// prefixed with "django:" use Django's format characters instead of Go's
+// reference time (see formatDjangoDate).
 func filterDate(in *Value, param *Value) (*Value, *Error) {
-	t, isTime := in.Interface().(time.Time)
-	if !isTime {
+	t, err := filterDateInput("filter:date", in)
+	if err != nil {
+		return nil, err
+	}
+	if format := param.String(); strings.HasPrefix(format, filterDateDjangoPrefix) {
+		return AsValue(formatDjangoDate(t, format[len(filterDateDjangoPrefix):])), nil
+	}
+	return AsValue(t.Format(param.String())), nil
+}
+
+const filterDateDjangoPrefix = "django:"
+
+// formatDjangoDate formats the time using the format characters of Django's
+// date filter; a backslash escapes the next character and all other
+// characters are kept as they are. The (English) characters supported are:
+//
+//	d, j     day of the month (01-31, 1-31)
+//	D, l     day of the week (Fri, Friday)
+//	S        English ordinal suffix of the day (st, nd, rd, th)
+//	w        day of the week (0 = Sunday - 6)
+//	z        day of the year (1-366)
+//	W        ISO-8601 week number
+//	m, n     month (01-12, 1-12)
+//	M, b, F  month name (Jan, jan, January)
+//	t        number of days in the month
+//	y, Y, o  year (99, 1999) and ISO-8601 week-numbering year
+//	L        whether it's a leap year (True, False)
+//	H, G     hour, 24-hour format (00-23, 0-23)
+//	h, g     hour, 12-hour format (01-12, 1-12)
+//	i, s, u  minutes (00-59), seconds (00-59), microseconds (000000-999999)
+//	a, A     a.m./p.m., AM/PM
+//	f, P     time with minutes if they aren't zero (1, 1:30; P also gives
+//	         a.m./p.m., "noon" and "midnight")
+//	e, T     time zone name (as given by the time's location), abbreviation
+//	O, Z     difference to UTC (+0200) and in seconds
+//	c, r, U  ISO-8601, RFC 5322 and Unix time
+func formatDjangoDate(t time.Time, format string) string {
+	var b strings.Builder
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if c == '\\' {
+			if i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			}
+			continue
+		}
+		b.WriteString(djangoDateChar(t, c))
+	}
+	return b.String()
+}
+
+func djangoDateChar(t time.Time, c rune) string {
+	hour12 := t.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+
+	switch c {
+	case 'd':
+		return t.Format("02")
+	case 'j':
+		return strconv.Itoa(t.Day())
+	case 'D':
+		return t.Format("Mon")
+	case 'l':
+		return t.Format("Monday")
+	case 'S':
+		return ordinalSuffix(t.Day())
+	case 'w':
+		return strconv.Itoa(int(t.Weekday()))
+	case 'z':
+		return strconv.Itoa(t.YearDay())
+	case 'W':
+		_, week := t.ISOWeek()
+		return strconv.Itoa(week)
+	case 'm':
+		return t.Format("01")
+	case 'n':
+		return strconv.Itoa(int(t.Month()))
+	case 'M':
+		return t.Format("Jan")
+	case 'b':
+		return strings.ToLower(t.Format("Jan"))
+	case 'F':
+		return t.Format("January")
+	case 't':
+		return strconv.Itoa(time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day())
+	case 'y':
+		return t.Format("06")
+	case 'Y':
+		return strconv.Itoa(t.Year())
+	case 'o':
+		year, _ := t.ISOWeek()
+		return strconv.Itoa(year)
+	case 'L':
+		if time.Date(t.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay() == 366 {
+			return "True"
+		}
+		return "False"
+	case 'H':
+		return t.Format("15")
+	case 'G':
+		return strconv.Itoa(t.Hour())
+	case 'h':
+		return fmt.Sprintf("%02d", hour12)
+	case 'g':
+		return strconv.Itoa(hour12)
+	case 'i':
+		return t.Format("04")
+	case 's':
+		return t.Format("05")
+	case 'u':
+		return fmt.Sprintf("%06d", t.Nanosecond()/1000)
+	case 'a':
+		if t.Hour() < 12 {
+			return "a.m."
+		}
+		return "p.m."
+	case 'A':
+		return t.Format("PM")
+	case 'f':
+		if t.Minute() == 0 {
+			return strconv.Itoa(hour12)
+		}
+		return fmt.Sprintf("%d:%02d", hour12, t.Minute())
+	case 'P':
+		switch {
+		case t.Minute() == 0 && t.Hour() == 0:
+			return "midnight"
+		case t.Minute() == 0 && t.Hour() == 12:
+			return "noon"
+		}
+		return djangoDateChar(t, 'f') + " " + djangoDateChar(t, 'a')
+	case 'e':
+		return t.Location().String()
+	case 'T':
+		return t.Format("MST")
+	case 'O':
+		return t.Format("-0700")
+	case 'Z':
+		_, offset := t.Zone()
+		return strconv.Itoa(offset)
+	case 'c':
+		return t.Format("2006-01-02T15:04:05.999999-07:00")
+	case 'r':
+		return t.Format(time.RFC1123Z)
+	case 'U':
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return string(c)
+}
+
+// filterTimefmtPresets are the (locale-neutral) layouts of the timefmt filter.
+var filterTimefmtPresets = map[string]string{
+	"short":   "2006-01-02 15:04",
+	"medium":  "Jan 2, 2006 15:04:05",
+	"long":    "January 2, 2006 15:04:05 MST",
+	"full":    "Monday, January 2, 2006 15:04:05 MST",
+	"date":    "2006-01-02",
+	"time":    "15:04:05",
+	"rfc3339": time.RFC3339,
+}
+
+// filterTimefmt works like the date filter, but takes the name of one of the
+// filterTimefmtPresets instead of a layout.
+func filterTimefmt(in *Value, param *Value) (*Value, *Error) {
+	layout, has := filterTimefmtPresets[param.String()]
+	if !has {
+		presets := make([]string, 0, len(filterTimefmtPresets))
+		for name := range filterTimefmtPresets {
+			presets = append(presets, name)
+		}
+		sort.Strings(presets)
 		return nil, &Error{
-			Sender:    "filter:date",
-			OrigError: errors.New("filter input argument must be of type 'time.Time'"),
+			Sender:    "filter:timefmt",
+			OrigError: fmt.Errorf("unknown preset '%s' (available: %s)", param.String(), strings.Join(presets, ", ")),
 		}
 	}
-	return AsValue(t.Format(param.String())), nil
+	t, err := filterDateInput("filter:timefmt", in)
+	if err != nil {
+		return nil, err
+	}
+	return AsValue(t.Format(layout)), nil
 }
 
+var filterNaturaltimeUnits = []struct {
+	duration time.Duration
+	singular string
+	plural   string
+}{
+	{365 * 24 * time.Hour, "a year", "years"},
+	{30 * 24 * time.Hour, "a month", "months"},
+	{7 * 24 * time.Hour, "a week", "weeks"},
+	{24 * time.Hour, "a day", "days"},
+	{time.Hour, "an hour", "hours"},
+	{time.Minute, "a minute", "minutes"},
+	{time.Second, "a second", "seconds"},
+}
+
+// filterNaturaltime describes the input time relative to now (or to the time
+// given as param) like "3 hours ago" or "in 2 days", using the largest
+// fitting unit.
+func filterNaturaltime(in *Value, param *Value) (*Value, *Error) {
+	t, err := filterDateInput("filter:naturaltime", in)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !param.IsNil() {
+		if now, err = filterDateInput("filter:naturaltime", param); err != nil {
+			return nil, err
+		}
+	}
+
+	delta := now.Sub(t)
+	future := delta < 0
+	if future {
+		delta = -delta
+	}
+	for _, unit := range filterNaturaltimeUnits {
+		count := int(delta / unit.duration)
+		if count < 1 {
+			continue
+		}
+		amount := unit.singular
+		if count > 1 {
+			amount = fmt.Sprintf("%d %s", count, unit.plural)
+		}
+		if future {
+			return AsValue("in " + amount), nil
+		}
+		return AsValue(amount + " ago"), nil
+	}
+	return AsValue("now"), nil
+}
+
+// filterDateInput converts the input of the date filters to a time.Time.
+func filterDateInput(sender string, in *Value) (time.Time, *Error) {
+	var t time.Time
+	switch {
+	case in.IsInteger():
+		t = time.Unix(int64(in.Integer()), 0).UTC()
+	case in.IsString():
+		parsed := false
+		for _, layout := range filterDateLayouts {
+			if pt, err := time.Parse(layout, in.String()); err == nil {
+				t, parsed = pt, true
+				break
+			}
+		}
+		if !parsed {
+			return t, &Error{
+				Sender:    sender,
+				OrigError: fmt.Errorf("can't parse '%s' as date", in.String()),
+			}
+		}
+	default:
+		var isTime bool
+		t, isTime = in.Interface().(time.Time)
+		if !isTime {
+			return t, &Error{
+				Sender:    sender,
+				OrigError: errors.New("filter input argument must be of type 'time.Time', an integer (Unix timestamp) or a string"),
+			}
+		}
+	}
+	return t, nil
+}
+
+// filterFloat converts numbers and numeric strings to a float. Other values
+// are the default given as param ({{ value|float:1.5 }}); without one they
+// are 0.0. Unlike int it stays lenient then, since templates rely on
+// {{ value|float }} never failing (like integer).
 func filterFloat(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(in.Float()), nil
+	switch {
+	case in.IsNumber():
+		return AsValue(in.Float()), nil
+	case in.IsString():
+		if f, err := strconv.ParseFloat(strings.TrimSpace(in.String()), 64); err == nil {
+			return AsValue(f), nil
+		}
+	}
+	if !param.IsNil() {
+		return param, nil
+	}
+	return AsValue(0.0), nil
+}
+
+// filterInt converts numbers (floats get truncated), booleans (1 or 0) and
+// numeric strings ("42" or "4.2") to an integer. Other values are an error,
+// unless a default is given as param: {{ value|int:0 }}. Unlike integer it
+// doesn't silently use 0.
+func filterInt(in *Value, param *Value) (*Value, *Error) {
+	switch {
+	case in.IsBool():
+		return AsValue(filterBoolToInt(in)), nil
+	case in.IsNumber():
+		return AsValue(in.Integer()), nil
+	case in.IsString():
+		s := strings.TrimSpace(in.String())
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return AsValue(int(i)), nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return AsValue(int(f)), nil
+		}
+	}
+	return filterCoercionFailed("int", "an integer", in, param)
+}
+
+func filterBoolToInt(in *Value) int {
+	if in.Bool() {
+		return 1
+	}
+	return 0
+}
+
+// filterCoercionFailed returns the default (param) of a failed conversion
+// or the error if there's none.
+func filterCoercionFailed(name, target string, in *Value, param *Value) (*Value, *Error) {
+	if !param.IsNil() {
+		return param, nil
+	}
+	if in.IsNil() {
+		return nil, &Error{
+			Sender:    "filter:" + name,
+			OrigError: fmt.Errorf("can't convert nil to %s", target),
+		}
+	}
+	if !in.IsString() {
+		return nil, &Error{
+			Sender:    "filter:" + name,
+			OrigError: fmt.Errorf("can't convert a value of type %s to %s", in.getResolvedValue().Type(), target),
+		}
+	}
+	return nil, &Error{
+		Sender:    "filter:" + name,
+		OrigError: fmt.Errorf("can't convert '%s' to %s", in.String(), target),
+	}
+}
+
+// filterBool converts the strings "true", "false", "1" and "0" (ignoring
+// the case) to the boolean; all other values are converted using the usual
+// truthiness (like within if-tags).
+func filterBool(in *Value, param *Value) (*Value, *Error) {
+	if in.IsString() {
+		switch strings.ToLower(strings.TrimSpace(in.String())) {
+		case "true", "1":
+			return AsValue(true), nil
+		case "false", "0":
+			return AsValue(false), nil
+		}
+	}
+	return AsValue(in.IsTrue()), nil
+}
+
+// filterString converts the value to its string representation (as it'd be
+// printed); safe values stay safe.
+func filterString(in *Value, param *Value) (*Value, *Error) {
+	if in.safe {
+		return AsSafeValue(in.String()), nil
+	}
+	return AsValue(in.String()), nil
 }
 
 func filterInteger(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(in.Integer()), nil
 }
 
+// filterIntcomma adds thousands separators to the integer part of a number:
+// 1234567.5 becomes "1,234,567.5". Other values are returned unchanged.
+func filterIntcomma(in *Value, param *Value) (*Value, *Error) {
+	var s string
+	switch {
+	case in.IsInteger():
+		s = in.String()
+	case in.IsFloat():
+		s = strconv.FormatFloat(in.Float(), 'f', -1, 64)
+	default:
+		return in, nil
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	fraction := ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		s, fraction = s[:idx], s[idx:]
+	}
+
+	return AsValue(sign + groupDigits(s, ",") + fraction), nil
+}
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// filterBase62encode encodes a non-negative integer using the digits 0-9,
+// A-Z and a-z (like for short, URL-friendly IDs).
+func filterBase62encode(in *Value, param *Value) (*Value, *Error) {
+	var n uint64
+	switch rv := in.getResolvedValue(); rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = rv.Uint()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return nil, &Error{
+				Sender:    "filter:base62encode",
+				OrigError: fmt.Errorf("can't encode the negative number %d", rv.Int()),
+			}
+		}
+		n = uint64(rv.Int())
+	default:
+		return nil, &Error{
+			Sender:    "filter:base62encode",
+			OrigError: fmt.Errorf("filter input argument must be an integer (got: '%s')", in.String()),
+		}
+	}
+
+	if n == 0 {
+		return AsValue("0"), nil
+	}
+	var digits []byte
+	for ; n > 0; n /= 62 {
+		digits = append([]byte{base62Alphabet[n%62]}, digits...)
+	}
+	return AsValue(string(digits)), nil
+}
+
+// filterBase62decode decodes a string encoded with base62encode.
+func filterBase62decode(in *Value, param *Value) (*Value, *Error) {
+	s := in.String()
+	if s == "" {
+		return nil, &Error{
+			Sender:    "filter:base62decode",
+			OrigError: errors.New("can't decode an empty string"),
+		}
+	}
+
+	var n uint64
+	for _, c := range s {
+		digit := strings.IndexRune(base62Alphabet, c)
+		if digit < 0 {
+			return nil, &Error{
+				Sender:    "filter:base62decode",
+				OrigError: fmt.Errorf("invalid base62 character '%c' in '%s'", c, s),
+			}
+		}
+		if n > (math.MaxInt64-uint64(digit))/62 {
+			return nil, &Error{
+				Sender:    "filter:base62decode",
+				OrigError: fmt.Errorf("'%s' exceeds the range of integers", s),
+			}
+		}
+		n = n*62 + uint64(digit)
+	}
+	return AsValue(int(n)), nil
+}
+
+// groupDigits inserts the separator between each group of three digits.
+func groupDigits(digits string, separator string) string {
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(separator)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// filterNumberformatLocales are the separators (decimal, thousands) of the
+// locales the numberformat filter knows by name.
+var filterNumberformatLocales = map[string][2]string{
+	"en": {".", ","},
+	"de": {",", "."},
+	"es": {",", "."},
+	"it": {",", "."},
+	"fr": {",", "\u202f"},
+	"ch": {".", "'"},
+}
+
+// filterPercentage formats a ratio as percentage: {{ 0.1234|percentage:1 }}
+// gives 12.3%. The arguments are decimals[:percentage] (default: no decimal
+// places); with true as second argument the number is a percentage already
+// (12.34 instead of 0.1234). Other values than numbers are returned
+// unchanged.
+func filterPercentage(in *Value, param *Value) (*Value, *Error) {
+	decimals, isPercentage := 0, false
+	if !param.IsNil() {
+		params := filterParameterList(param)
+		if len(params) > 2 {
+			return nil, &Error{
+				Sender:    "filter:percentage",
+				OrigError: fmt.Errorf("expected decimals[:percentage] (got %d arguments)", len(params)),
+			}
+		}
+		var err error
+		decimals, err = strconv.Atoi(strings.TrimSpace(params[0].String()))
+		if err != nil || decimals < 0 {
+			return nil, &Error{
+				Sender:    "filter:percentage",
+				OrigError: fmt.Errorf("the number of decimal places must be a non-negative integer (got: '%s')", params[0].String()),
+			}
+		}
+		isPercentage = len(params) > 1 && params[1].IsTrue()
+	}
+
+	if !in.IsNumber() {
+		return in, nil
+	}
+	f := in.Float()
+	if !isPercentage {
+		f *= 100
+	}
+	return AsValue(strconv.FormatFloat(f, 'f', decimals, 64) + "%"), nil
+}
+
+// filterNumberformat formats a number with the given number of decimal
+// places and separators. The arguments are either decimals[:decimal
+// separator[:thousands separator]] (like 2:",":"." for 1.234,56; the
+// defaults are "." and ",") or locale[:decimals] using one of the
+// filterNumberformatLocales (like "de":2). A single string argument may hold
+// all of them ("de:2"). Without argument, the number is formatted like "en":2.
+// Other values than numbers are returned unchanged.
+func filterNumberformat(in *Value, param *Value) (*Value, *Error) {
+	if !in.IsNumber() {
+		return in, nil
+	}
+
+	decimals, decimalSep, thousandsSep := "2", ".", ","
+	var parts []string
+	if params := filterParameterList(param); len(params) > 1 {
+		for _, p := range params {
+			parts = append(parts, p.String())
+		}
+	} else {
+		parts = strings.Split(param.String(), ":")
+	}
+	if locale, has := filterNumberformatLocales[parts[0]]; has {
+		if len(parts) > 2 {
+			return nil, &Error{
+				Sender:    "filter:numberformat",
+				OrigError: fmt.Errorf("expected 'locale[:decimals]' (got: '%s')", strings.Join(parts, ":")),
+			}
+		}
+		decimalSep, thousandsSep = locale[0], locale[1]
+		parts = parts[1:]
+	} else {
+		if len(parts) > 3 {
+			return nil, &Error{
+				Sender:    "filter:numberformat",
+				OrigError: fmt.Errorf("expected 'decimals[:decimal separator[:thousands separator]]' (got: '%s')", strings.Join(parts, ":")),
+			}
+		}
+		if len(parts) > 1 {
+			decimalSep = parts[1]
+		}
+		if len(parts) > 2 {
+			thousandsSep = parts[2]
+		}
+	}
+	if len(parts) > 0 && parts[0] != "" {
+		decimals = parts[0]
+	}
+
+	places, err := strconv.Atoi(decimals)
+	if err != nil || places < 0 {
+		return nil, &Error{
+			Sender:    "filter:numberformat",
+			OrigError: fmt.Errorf("decimals must be a non-negative integer or the name of a locale (got: '%s')", decimals),
+		}
+	}
+
+	s := strconv.FormatFloat(in.Float(), 'f', places, 64)
+	if in.IsInteger() {
+		s = strconv.Itoa(in.Integer())
+		if places > 0 {
+			s += "." + strings.Repeat("0", places)
+		}
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+		if strings.Trim(s, "0.") == "" {
+			sign = "" // rounded to zero
+		}
+	}
+	fraction := ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		s, fraction = s[:idx], decimalSep+s[idx+1:]
+	}
+	return AsValue(sign + groupDigits(s, thousandsSep) + fraction), nil
+}
+
+var filterIntwordUnits = []string{"million", "billion", "trillion", "quadrillion", "quintillion",
+	"sextillion", "septillion", "octillion", "nonillion", "decillion"}
+
+// filterIntword converts large numbers (of at least one million) to a
+// friendly text representation like "1.2 million". Smaller numbers (and
+// other values) are returned unchanged.
+func filterIntword(in *Value, param *Value) (*Value, *Error) {
+	if !in.IsNumber() {
+		return in, nil
+	}
+	f := in.Float()
+	abs := math.Abs(f)
+	if abs < 1e6 {
+		return in, nil
+	}
+
+	exp := 1e6
+	for i, unit := range filterIntwordUnits {
+		scaled := math.Round(abs/exp*10) / 10
+		if scaled < 1000 || i == len(filterIntwordUnits)-1 {
+			return AsValue(fmt.Sprintf("%.1f %s", math.Copysign(scaled, f), unit)), nil
+		}
+		exp *= 1000
+	}
+	return in, nil
+}
+
+var filterApnumberWords = []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+
+// filterApnumber spells out the integers 1 to 9 (AP style); other values are
+// returned unchanged.
+func filterApnumber(in *Value, param *Value) (*Value, *Error) {
+	if !in.IsInteger() {
+		return in, nil
+	}
+	if n := in.Integer(); n >= 1 && n <= 9 {
+		return AsValue(filterApnumberWords[n-1]), nil
+	}
+	return in, nil
+}
+
+// filterOrdinal converts an integer to its ordinal as a string: 1st, 2nd,
+// 3rd, 4th, 11th, ... Other values are returned unchanged.
+func filterOrdinal(in *Value, param *Value) (*Value, *Error) {
+	if !in.IsNumber() {
+		return in, nil
+	}
+	n := in.Integer()
+	return AsValue(fmt.Sprintf("%d%s", n, ordinalSuffix(n))), nil
+}
+
+// ordinalSuffix returns the English ordinal suffix of n (st, nd, rd or th).
+func ordinalSuffix(n int) string {
+	if n < 0 {
+		n = -n
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		return "th"
+	}
+	switch n % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
 func filterLinebreaks(in *Value, param *Value) (*Value, *Error) {
 	if in.Len() == 0 {
 		return in, nil
@@ -621,8 +2402,49 @@ func filterSplit(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(chunks), nil
 }
 
+var filterLinebreaksbrTagRegexp = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+// filterLinebreaksbr escapes the input and converts newlines into "<br />".
+// Line endings are normalized first (\r\n and \r become \n). Existing
+// <br>, <br/> and <br /> tags (case-insensitive) are kept unescaped; a
+// newline following such a tag (only spaces or tabs in between) is kept
+// as-is instead of being converted. Safe input (like the output of an
+// earlier linebreaksbr) isn't escaped again, so the filter is idempotent.
 func filterLinebreaksbr(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(strings.Replace(in.String(), "\n", "<br />", -1)), nil
+	s := strings.Replace(in.String(), "\r\n", "\n", -1)
+	s = strings.Replace(s, "\r", "\n", -1)
+
+	var b bytes.Buffer
+
+	last := 0
+	for _, loc := range filterLinebreaksbrTagRegexp.FindAllStringIndex(s, -1) {
+		linebreaksbrText(&b, s[last:loc[0]], last > 0, !in.safe)
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	linebreaksbrText(&b, s[last:], last > 0, !in.safe)
+
+	return AsSafeValue(b.String()), nil
+}
+
+// linebreaksbrText writes the (escaped) text with converted newlines; if
+// the text follows a <br>-tag, a leading newline is kept unconverted.
+func linebreaksbrText(b *bytes.Buffer, text string, afterBr bool, escape bool) {
+	lines := strings.Split(text, "\n")
+	for idx, line := range lines {
+		if idx > 0 {
+			if idx == 1 && afterBr && strings.Trim(lines[0], " \t") == "" {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("<br />")
+			}
+		}
+		if escape {
+			escaped, _ := filterEscape(AsValue(line), nil)
+			line = escaped.String()
+		}
+		b.WriteString(line)
+	}
 }
 
 func filterLinenumbers(in *Value, param *Value) (*Value, *Error) {
@@ -634,16 +2456,78 @@ func filterLinenumbers(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.Join(output, "\n")), nil
 }
 
+// filterMask redacts a string, keeping only its last n characters (runes):
+// {{ card|mask:4 }} gives ************1234. The arguments are
+// n[:char[:prefix]]: char replaces the masked characters (default: *)
+// and with prefix set to true the first n characters are kept instead.
+// Strings not longer than n are masked completely, so short secrets aren't
+// revealed.
+func filterMask(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 3 {
+		return nil, &Error{
+			Sender:    "filter:mask",
+			OrigError: fmt.Errorf("expected n[:char[:prefix]] (got %d arguments)", len(params)),
+		}
+	}
+	keep, err := strconv.Atoi(strings.TrimSpace(params[0].String()))
+	if err != nil || keep < 0 {
+		return nil, &Error{
+			Sender:    "filter:mask",
+			OrigError: fmt.Errorf("the number of kept characters must be a non-negative integer (got: '%s')", params[0].String()),
+		}
+	}
+	maskChar := "*"
+	if len(params) > 1 && params[1].String() != "" {
+		if utf8.RuneCountInString(params[1].String()) != 1 {
+			return nil, &Error{
+				Sender:    "filter:mask",
+				OrigError: fmt.Errorf("the mask character must be a single character (got: '%s')", params[1].String()),
+			}
+		}
+		maskChar = params[1].String()
+	}
+	prefix := len(params) > 2 && params[2].IsTrue()
+
+	runes := []rune(in.String())
+	if len(runes) <= keep {
+		return AsValue(strings.Repeat(maskChar, len(runes))), nil
+	}
+	masked := strings.Repeat(maskChar, len(runes)-keep)
+	if prefix {
+		return AsValue(string(runes[:keep]) + masked), nil
+	}
+	return AsValue(masked + string(runes[len(runes)-keep:])), nil
+}
+
 func filterLjust(in *Value, param *Value) (*Value, *Error) {
-	times := param.Integer() - in.Len()
-	if times < 0 {
-		times = 0
+	padding, fill, err := filterPadding("ljust", in, param)
+	if err != nil {
+		return nil, err
 	}
-	return AsValue(fmt.Sprintf("%s%s", in.String(), strings.Repeat(" ", times))), nil
+	return AsValue(in.String() + strings.Repeat(fill, padding)), nil
 }
 
+// filterUrlencode encodes the input as a single query value (spaces become
+// "+", slashes get encoded). With the argument "path" the input is encoded
+// as a URL path instead: each segment gets escaped, but the path separators
+// are kept (like {{ path|urlencode:"path" }}).
 func filterUrlencode(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(url.QueryEscape(in.String())), nil
+	switch mode := param.String(); mode {
+	case "":
+		return AsValue(url.QueryEscape(in.String())), nil
+	case "path":
+		segments := strings.Split(in.String(), "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		return AsValue(strings.Join(segments, "/")), nil
+	default:
+		return nil, &Error{
+			Sender:    "filter:urlencode",
+			OrigError: fmt.Errorf("unknown mode '%s' (must be 'path' or omitted)", mode),
+		}
+	}
 }
 
 // TODO: This regexp could do some work
@@ -734,6 +2618,13 @@ func filterUrlizetrunc(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(s), nil
 }
 
+// filterPprint dumps the Go representation of the input (debugging aid).
+// The output is not marked as safe, so it will be escaped within an
+// autoescape context and can be shown within a <pre> safely.
+func filterPprint(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(fmt.Sprintf("%#v", in.Interface())), nil
+}
+
 func filterStringformat(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(fmt.Sprintf(param.String(), in.Interface())), nil
 }
@@ -824,16 +2715,202 @@ func filterRemovetags(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.TrimSpace(s)), nil
 }
 
+// filterReplace replaces the occurrences of a string; the arguments are
+// search[:replacement[:count]] (like {{ s|replace:"foo":"bar":1 }}). A
+// search delimited by slashes is a regular expression, the replacement may
+// refer to its groups ({{ s|replace:"/(\\d+)/":"#$1" }}). An empty search
+// leaves the input unchanged.
+func filterReplace(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 3 {
+		return nil, &Error{
+			Sender:    "filter:replace",
+			OrigError: fmt.Errorf("expected search[:replacement[:count]] (got %d arguments)", len(params)),
+		}
+	}
+
+	search, regex := params[0].String(), false
+	if len(search) > 1 && strings.HasPrefix(search, "/") && strings.HasSuffix(search, "/") {
+		search, regex = search[1:len(search)-1], true
+	}
+	replacement := ""
+	if len(params) > 1 {
+		replacement = params[1].String()
+	}
+	count := -1
+	if len(params) > 2 {
+		n, err := strconv.Atoi(params[2].String())
+		if err != nil || n < 1 {
+			return nil, &Error{
+				Sender:    "filter:replace",
+				OrigError: fmt.Errorf("the count must be a positive integer (got: '%s')", params[2].String()),
+			}
+		}
+		count = n
+	}
+	return filterReplaceHelper(in, search, replacement, count, regex)
+}
+
+// filterReplaceKwargs is the keyword arguments form of replace:
+// replace(old="foo", new="bar", count=1, regex=false)
+func filterReplaceKwargs(in *Value, kwargs map[string]*Value) (*Value, *Error) {
+	old, replacement, count, regex := "", "", -1, false
+	for name, value := range kwargs {
+		switch name {
+		case "old":
+			old = value.String()
+		case "new":
+			replacement = value.String()
+		case "count":
+			if !value.IsInteger() || value.Integer() < 1 {
+				return nil, &Error{
+					Sender:    "filter:replace",
+					OrigError: fmt.Errorf("the count must be a positive integer (got: '%s')", value.String()),
+				}
+			}
+			count = value.Integer()
+		case "regex":
+			regex = value.IsTrue()
+		default:
+			return nil, &Error{
+				Sender:    "filter:replace",
+				OrigError: fmt.Errorf("unknown keyword argument '%s'", name),
+			}
+		}
+	}
+	if _, has := kwargs["old"]; !has {
+		return nil, &Error{
+			Sender:    "filter:replace",
+			OrigError: errors.New("the keyword argument 'old' is required"),
+		}
+	}
+	return filterReplaceHelper(in, old, replacement, count, regex)
+}
+
+// filterReplaceHelper replaces the first count (-1 = all) occurrences of
+// search in the input.
+func filterReplaceHelper(in *Value, search, replacement string, count int, regex bool) (*Value, *Error) {
+	s := in.String()
+	if search == "" {
+		return AsValue(s), nil
+	}
+	if !regex {
+		return AsValue(strings.Replace(s, search, replacement, count)), nil
+	}
+
+	re, err := regexp.Compile(search)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:replace",
+			OrigError: fmt.Errorf("invalid regular expression '%s': %v", search, err),
+		}
+	}
+	var result []byte
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, count) {
+		result = append(result, s[last:m[0]]...)
+		result = re.ExpandString(result, replacement, s, m)
+		last = m[1]
+	}
+	return AsValue(string(append(result, s[last:]...))), nil
+}
+
 func filterRjust(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(fmt.Sprintf(fmt.Sprintf("%%%ds", param.Integer()), in.String())), nil
+	padding, fill, err := filterPadding("rjust", in, param)
+	if err != nil {
+		return nil, err
+	}
+	return AsValue(strings.Repeat(fill, padding) + in.String()), nil
+}
+
+// filterColumns splits a list into the given number of columns (lists as
+// [][]interface{}) of balanced sizes, the first columns taking the
+// remainder: 7 items in 3 columns are distributed as 3, 2 and 2 items. With
+// true as second argument ({{ items|columns:3:true }}) the items are
+// distributed round-robin (the first item goes into the first column, the
+// second into the second, ...) instead of sequentially. Other values than
+// lists are returned unchanged.
+func filterColumns(in *Value, param *Value) (*Value, *Error) {
+	params := filterParameterList(param)
+	if len(params) > 2 {
+		return nil, &Error{
+			Sender:    "filter:columns",
+			OrigError: fmt.Errorf("expected n[:round-robin] (got %d arguments)", len(params)),
+		}
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(params[0].String()))
+	if err != nil || count <= 0 {
+		return nil, &Error{
+			Sender:    "filter:columns",
+			OrigError: fmt.Errorf("the number of columns must be a positive integer (got: '%s')", params[0].String()),
+		}
+	}
+	roundRobin := len(params) > 1 && params[1].IsTrue()
+
+	if kind := in.getResolvedValue().Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return in, nil
+	}
+
+	length := in.Len()
+	columns := make([][]interface{}, count)
+	start := 0
+	for i := range columns {
+		// Sequentially the first length % count columns get one more item
+		size := length / count
+		if i < length%count {
+			size++
+		}
+		columns[i] = make([]interface{}, 0, size)
+		if !roundRobin {
+			for j := start; j < start+size; j++ {
+				columns[i] = append(columns[i], in.Index(j).Interface())
+			}
+			start += size
+		}
+	}
+	if roundRobin {
+		for i := 0; i < length; i++ {
+			columns[i%count] = append(columns[i%count], in.Index(i).Interface())
+		}
+	}
+	return AsValue(columns), nil
 }
 
+// filterSlice slices like Python does: param is "start:stop[:step]" where
+// all parts can be omitted, negative indices count from the end and a
+// negative step walks backwards. Out-of-range bounds are clamped.
 func filterSlice(in *Value, param *Value) (*Value, *Error) {
 	comp := strings.Split(param.String(), ":")
-	if len(comp) != 2 {
+	if len(comp) != 2 && len(comp) != 3 {
 		return nil, &Error{
 			Sender:    "filter:slice",
-			OrigError: errors.New("Slice string must have the format 'from:to' [from/to can be omitted, but the ':' is required]"),
+			OrigError: errors.New("Slice string must have the format 'start:stop[:step]' [start/stop/step can be omitted, but the ':' is required]"),
+		}
+	}
+
+	var bounds [3]*int
+	for i, c := range comp {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			return nil, &Error{
+				Sender:    "filter:slice",
+				OrigError: fmt.Errorf("slice index must be an integer (got: '%s')", c),
+			}
+		}
+		bounds[i] = &n
+	}
+	step := 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return nil, &Error{
+			Sender:    "filter:slice",
+			OrigError: errors.New("slice step cannot be zero"),
 		}
 	}
 
@@ -841,19 +2918,46 @@ func filterSlice(in *Value, param *Value) (*Value, *Error) {
 		return in, nil
 	}
 
-	from := AsValue(comp[0]).Integer()
-	to := in.Len()
-
-	if from > to {
-		from = to
+	length := in.Len()
+	lower, upper := 0, length
+	if step < 0 {
+		lower, upper = -1, length-1
+	}
+	index := func(bound *int, def int) int {
+		if bound == nil {
+			return def
+		}
+		i := *bound
+		if i < 0 {
+			i += length
+		}
+		return min(max(i, lower), upper)
+	}
+	var start, stop int
+	if step > 0 {
+		start, stop = index(bounds[0], lower), index(bounds[1], upper)
+	} else {
+		start, stop = index(bounds[0], upper), index(bounds[1], lower)
 	}
 
-	vto := AsValue(comp[1]).Integer()
-	if vto >= from && vto <= in.Len() {
-		to = vto
+	if step == 1 {
+		return in.Slice(start, max(start, stop)), nil
 	}
 
-	return in.Slice(from, to), nil
+	rv := in.getResolvedValue()
+	if rv.Kind() == reflect.String {
+		runes := []rune(rv.String())
+		var result []rune
+		for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+			result = append(result, runes[i])
+		}
+		return AsValue(string(result)), nil
+	}
+	result := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), 0, 0)
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		result = reflect.Append(result, rv.Index(i))
+	}
+	return AsValue(result.Interface()), nil
 }
 
 func filterTitle(in *Value, param *Value) (*Value, *Error) {
@@ -864,7 +2968,22 @@ func filterTitle(in *Value, param *Value) (*Value, *Error) {
 }
 
 func filterWordcount(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(len(strings.Fields(in.String()))), nil
+	count := 0
+	for _, field := range strings.Fields(in.String()) {
+		// Scripts which don't separate words by spaces (like Chinese or
+		// Japanese) count every character as a word
+		inWord := false
+		for _, r := range field {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+				count++
+				inWord = false
+			} else if !inWord {
+				count++
+				inWord = true
+			}
+		}
+	}
+	return AsValue(count), nil
 }
 
 func filterWordwrap(in *Value, param *Value) (*Value, *Error) {
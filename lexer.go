@@ -27,6 +27,16 @@ var (
 	tokenIdentifierCharsWithDigits = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_0123456789"
 	tokenDigits                    = "0123456789"
 
+	// Digits allowed after a base prefix (0x, 0b, 0o)
+	tokenBaseDigits = map[rune]string{
+		'x': "0123456789abcdefABCDEF",
+		'X': "0123456789abcdefABCDEF",
+		'b': "01",
+		'B': "01",
+		'o': "01234567",
+		'O': "01234567",
+	}
+
 	// Available symbols in pongo2 (within filters/tag)
 	TokenSymbols = []string{
 		// 3-Char symbols
@@ -37,10 +47,14 @@ var (
 
 		// 1-Char symbol
 		"(", ")", "+", "-", "*", "<", ">", "/", "^", ",", ".", "!", "|", ":", "=", "%",
+		"[", "]", "{", "}",
 	}
 
 	// Available keywords in pongo2
 	TokenKeywords = []string{"in", "and", "or", "not", "true", "false", "as", "export"}
+
+	// Tags whose content is emitted as-is (raw is an alias of verbatim)
+	tokenVerbatimTags = []string{"verbatim", "raw"}
 )
 
 type TokenType int
@@ -51,6 +65,9 @@ type Token struct {
 	Line            int
 	Col             int
 	TrimWhitespaces bool
+
+	// HTML within a verbatim-tag; it's never modified (see protectedWriter)
+	verbatim bool
 }
 
 type lexerStateFn func() lexerStateFn
@@ -68,6 +85,7 @@ type lexer struct {
 	col       int
 
 	inVerbatim   bool
+	verbatimTag  string // "verbatim" or its alias "raw"
 	verbatimName string
 }
 
@@ -119,6 +137,7 @@ func lex(name string, input string) ([]*Token, *Error) {
 			Line:      errtoken.Line,
 			Column:    errtoken.Col,
 			Sender:    "lexer",
+			Phase:     PhaseLex,
 			OrigError: errors.New(errtoken.Val),
 		}
 	}
@@ -142,6 +161,10 @@ func (l *lexer) emit(t TokenType) {
 		Col:      l.startcol,
 	}
 
+	if t == TokenHTML && l.inVerbatim {
+		tok.verbatim = true
+	}
+
 	if t == TokenString {
 		// Escape sequence \" in strings
 		tok.Val = strings.Replace(tok.Val, `\"`, `"`, -1)
@@ -225,30 +248,42 @@ func (l *lexer) run() {
 	for {
 		// TODO: Support verbatim tag names
 		// https://docs.djangoproject.com/en/dev/ref/templates/builtins/#verbatim
+		//
+		// The content of a verbatim- (or raw-) tag ends at the first end-tag
+		// of the same spelling; there is no nesting. Everything else inside,
+		// including the other spelling's tags, is emitted untouched. Hence a
+		// literal "{% endraw %}" can be produced by wrapping it in
+		// verbatim-tags (and vice versa).
 		if l.inVerbatim {
 			name := l.verbatimName
 			if name != "" {
 				name += " "
 			}
-			if strings.HasPrefix(l.input[l.pos:], fmt.Sprintf("{%% endverbatim %s%%}", name)) { // end verbatim
+			if strings.HasPrefix(l.input[l.pos:], fmt.Sprintf("{%% end%s %s%%}", l.verbatimTag, name)) { // end verbatim
 				if l.pos > l.start {
 					l.emit(TokenHTML)
 				}
-				w := len("{% endverbatim %}")
+				w := len(fmt.Sprintf("{%% end%s %%}", l.verbatimTag))
 				l.pos += w
 				l.col += w
 				l.ignore()
 				l.inVerbatim = false
 			}
-		} else if strings.HasPrefix(l.input[l.pos:], "{% verbatim %}") { // tag
-			if l.pos > l.start {
-				l.emit(TokenHTML)
+		} else {
+			for _, tag := range tokenVerbatimTags {
+				if strings.HasPrefix(l.input[l.pos:], fmt.Sprintf("{%% %s %%}", tag)) { // tag
+					if l.pos > l.start {
+						l.emit(TokenHTML)
+					}
+					l.inVerbatim = true
+					l.verbatimTag = tag
+					w := len(fmt.Sprintf("{%% %s %%}", tag))
+					l.pos += w
+					l.col += w
+					l.ignore()
+					break
+				}
 			}
-			l.inVerbatim = true
-			w := len("{% verbatim %}")
-			l.pos += w
-			l.col += w
-			l.ignore()
 		}
 
 		if !l.inVerbatim {
@@ -313,7 +348,7 @@ func (l *lexer) run() {
 	}
 
 	if l.inVerbatim {
-		l.errorf("verbatim-tag not closed, got EOF.")
+		l.errorf("%s-tag not closed, got EOF.", l.verbatimTag)
 	}
 }
 
@@ -378,12 +413,28 @@ func (l *lexer) stateIdentifier() lexerStateFn {
 }
 
 func (l *lexer) stateNumber() lexerStateFn {
-	l.acceptRun(tokenDigits)
-	if l.accept(tokenIdentifierCharsWithDigits) {
+	if l.value() == "0" {
+		if digits, isPrefix := tokenBaseDigits[l.peek()]; isPrefix {
+			l.next() // consume the base prefix (x, b or o)
+			return l.stateBaseNumber(digits)
+		}
+	}
+
+	l.acceptRun(tokenDigits + "_")
+	if l.peek() == 'e' || l.peek() == 'E' {
+		return l.stateExponent()
+	}
+	if l.accept(tokenIdentifierChars) {
 		// This seems to be an identifier starting with a number.
 		// See https://github.com/flosch/pongo2/issues/151
 		return l.stateIdentifier()
 	}
+
+	// Digit separators (1_000); variables named like 12345_123 are still
+	// accessible, see separatedIntResolver and issue #151
+	if strings.Contains(l.value(), "_") && !validDigitSeparators(l.value()) {
+		return l.errorf("Malformed number literal '%s'.", l.value())
+	}
 	/*
 		Maybe context-sensitive number lexing?
 		* comments.0.Text // first comment
@@ -402,6 +453,68 @@ func (l *lexer) stateNumber() lexerStateFn {
 	return l.stateCode
 }
 
+// stateBaseNumber lexes hexadecimal, binary and octal literals like 0xFF,
+// 0b1010 or 0o17. The base prefix has already been consumed.
+func (l *lexer) stateBaseNumber(digits string) lexerStateFn {
+	l.acceptRun(digits + "_")
+	if l.accept(tokenIdentifierCharsWithDigits) {
+		l.acceptRun(tokenIdentifierCharsWithDigits)
+		return l.errorf("Malformed number literal '%s'.", l.value())
+	}
+	if !validDigitSeparators(l.value()[2:]) {
+		return l.errorf("Malformed number literal '%s'.", l.value())
+	}
+	l.emit(TokenNumber)
+	return l.stateCode
+}
+
+// stateExponent lexes the exponent part of a number in scientific notation
+// (1e6, 1.5e-3, 2E+4). The mantissa has already been consumed; l.peek()
+// returns the 'e' or 'E'.
+func (l *lexer) stateExponent() lexerStateFn {
+	mantissa := l.value()
+	if strings.Contains(mantissa, "_") && !validDigitSeparators(mantissa) {
+		return l.errorf("Malformed number literal '%s'.", mantissa)
+	}
+
+	rest := l.input[l.pos+1:] // everything after the 'e'
+	signed := rest != "" && (rest[0] == '+' || rest[0] == '-')
+	if signed {
+		rest = rest[1:]
+	}
+
+	if rest == "" || strings.IndexByte(tokenDigits, rest[0]) < 0 {
+		if !signed && rest != "" && strings.IndexByte(tokenIdentifierChars, rest[0]) >= 0 {
+			// Identifier starting with a number (like 1ex), see issue #151
+			return l.stateIdentifier()
+		}
+		l.next() // consume 'e'
+		l.accept("+-")
+		return l.errorf("Malformed number literal '%s': exponent has no digits.", l.value())
+	}
+
+	l.next() // consume 'e'
+	l.accept("+-")
+	l.acceptRun(tokenDigits)
+	if l.accept(tokenIdentifierChars) {
+		if !signed {
+			// Identifier starting with a number (like 1e6x), see issue #151
+			return l.stateIdentifier()
+		}
+		l.acceptRun(tokenIdentifierCharsWithDigits)
+		return l.errorf("Malformed number literal '%s'.", l.value())
+	}
+	l.emit(TokenNumber)
+	return l.stateCode
+}
+
+// validDigitSeparators reports whether s contains at least one digit and
+// every underscore in s is followed by a digit (Go-style digit separators).
+func validDigitSeparators(s string) bool {
+	s = strings.TrimPrefix(s, "_")
+	return s != "" && !strings.Contains(s, "__") && !strings.HasSuffix(s, "_")
+}
+
 func (l *lexer) stateString() lexerStateFn {
 	quotationMark := l.value()
 	l.ignore()
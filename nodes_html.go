@@ -8,16 +8,89 @@ type nodeHTML struct {
 	token     *Token
 	trimLeft  bool
 	trimRight bool
+
+	// Whether the text directly follows a %} (for TrimBlocks) or precedes a
+	// {% (for LStripBlocks)
+	afterTag  bool
+	beforeTag bool
 }
 
 func (n *nodeHTML) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	res := n.token.Val
+	if ctx != nil && ctx.options != nil && !n.token.verbatim {
+		// Issue #94 https://github.com/flosch/pongo2/issues/94
+		// If an application configures pongo2 template to trim_blocks,
+		// the first newline after a template tag is removed automatically (like in PHP).
+		if n.afterTag && ctx.options.TrimBlocks && strings.HasPrefix(res, "\n") {
+			res = res[1:]
+		}
+		if n.beforeTag && ctx.options.LStripBlocks {
+			res = strings.TrimRight(res, "\t ")
+		}
+	}
 	if n.trimLeft {
 		res = strings.TrimLeft(res, tokenSpaceChars)
 	}
 	if n.trimRight {
 		res = strings.TrimRight(res, tokenSpaceChars)
 	}
+	if n.token.verbatim {
+		writeProtected(writer, res)
+		return nil
+	}
 	writer.WriteString(res)
 	return nil
 }
+
+// nodeHTMLRun replaces consecutive HTML nodes (for example separated by
+// comments) after parsing; the text is joined upfront so it's written at once.
+type nodeHTMLRun struct {
+	nodes []*nodeHTML
+	text  string
+}
+
+func (n *nodeHTMLRun) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// The joined text is untrimmed
+	if ctx != nil && ctx.options != nil && (ctx.options.TrimBlocks || ctx.options.LStripBlocks) {
+		for _, node := range n.nodes {
+			node.Execute(ctx, writer)
+		}
+		return nil
+	}
+	writer.WriteString(n.text)
+	return nil
+}
+
+// coalesceHTML joins consecutive HTML nodes into a nodeHTMLRun each. The
+// content of verbatim-tags is kept separately (it's protected text).
+func coalesceHTML(nodes []INode) []INode {
+	result := make([]INode, 0, len(nodes))
+	for i := 0; i < len(nodes); {
+		html, ok := nodes[i].(*nodeHTML)
+		if !ok || html.token.verbatim {
+			result = append(result, nodes[i])
+			i++
+			continue
+		}
+
+		run := []*nodeHTML{html}
+		for i++; i < len(nodes); i++ {
+			next, ok := nodes[i].(*nodeHTML)
+			if !ok || next.token.verbatim {
+				break
+			}
+			run = append(run, next)
+		}
+		if len(run) == 1 {
+			result = append(result, html)
+			continue
+		}
+
+		var b strings.Builder
+		for _, node := range run {
+			node.Execute(nil, &b)
+		}
+		result = append(result, &nodeHTMLRun{nodes: run, text: b.String()})
+	}
+	return result
+}
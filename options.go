@@ -8,12 +8,17 @@ type Options struct {
 
 	// If this is set to true leading spaces and tabs are stripped from the start of a line to a block. Defaults to false
 	LStripBlocks bool
+
+	// If this is set to true, calling a built-in filter without a required argument (or
+	// with an argument it doesn't take) is a parse error. Defaults to false.
+	StrictFilterArguments bool
 }
 
 func newOptions() *Options {
 	return &Options{
-		TrimBlocks:   false,
-		LStripBlocks: false,
+		TrimBlocks:            false,
+		LStripBlocks:          false,
+		StrictFilterArguments: false,
 	}
 }
 
@@ -21,6 +26,7 @@ func newOptions() *Options {
 func (opt *Options) Update(other *Options) *Options {
 	opt.TrimBlocks = other.TrimBlocks
 	opt.LStripBlocks = other.LStripBlocks
+	opt.StrictFilterArguments = other.StrictFilterArguments
 
 	return opt
 }
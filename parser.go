@@ -151,6 +151,48 @@ func (p *Parser) PeekTypeN(shift int, typ TokenType) *Token {
 	return nil
 }
 
+// peekQualifiedName returns the (possibly namespaced, like myorg.slugify)
+// name of a filter or tag at the given shift and the number of its tokens;
+// the dots must not be surrounded by whitespace. The returned token is the
+// first one holding the whole name; it's nil if there's no identifier.
+func (p *Parser) peekQualifiedName(shift int) (*Token, int) {
+	first := p.PeekTypeN(shift, TokenIdentifier)
+	if first == nil {
+		return nil, 0
+	}
+
+	name, count, last := first.Val, 1, first
+	for {
+		dot := p.PeekN(shift+count, TokenSymbol, ".")
+		ident := p.PeekTypeN(shift+count+1, TokenIdentifier)
+		if dot == nil || ident == nil || !adjacentTokens(last, dot) || !adjacentTokens(dot, ident) {
+			break
+		}
+		name += "." + ident.Val
+		count += 2
+		last = ident
+	}
+	if count == 1 {
+		return first, 1
+	}
+
+	qualified := *first
+	qualified.Val = name
+	return &qualified, count
+}
+
+// matchQualifiedName consumes a name like peekQualifiedName returns it.
+func (p *Parser) matchQualifiedName() *Token {
+	t, count := p.peekQualifiedName(0)
+	p.ConsumeN(count)
+	return t
+}
+
+// adjacentTokens reports whether there's no whitespace between a and b.
+func adjacentTokens(a, b *Token) bool {
+	return a.Line == b.Line && b.Col == a.Col+len(a.Val)
+}
+
 // Returns the UNCONSUMED token count.
 func (p *Parser) Remaining() int {
 	return len(p.tokens) - p.idx
@@ -181,6 +223,12 @@ func (p *Parser) GetR(shift int) *Token {
 // the token's position information. If not provided, it will
 // automatically use the CURRENT token's position information.
 func (p *Parser) Error(msg string, token *Token) *Error {
+	return p.OrigError(errors.New(msg), token)
+}
+
+// OrigError works like Error, but keeps the given error as the cause (see
+// Error.Unwrap).
+func (p *Parser) OrigError(err error, token *Token) *Error {
 	if token == nil {
 		// Set current token
 		token = p.Current()
@@ -200,10 +248,11 @@ func (p *Parser) Error(msg string, token *Token) *Error {
 		Template:  p.template,
 		Filename:  p.name,
 		Sender:    "parser",
+		Phase:     PhaseParse,
 		Line:      line,
 		Column:    col,
 		Token:     token,
-		OrigError: errors.New(msg),
+		OrigError: err,
 	}
 }
 
@@ -218,7 +267,7 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, *Error) {
 	for p.Remaining() > 0 {
 		// New tag, check whether we have to stop wrapping here
 		if p.Peek(TokenSymbol, "{%") != nil {
-			tagIdent := p.PeekTypeN(1, TokenIdentifier)
+			tagIdent, nameTokens := p.peekQualifiedName(1)
 
 			if tagIdent != nil {
 				// We've found a (!) end-tag
@@ -234,12 +283,13 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, *Error) {
 				// We only process the tag if we've found an end tag
 				if found {
 					// Okay, endtag found.
-					p.ConsumeN(2) // '{%' tagname
+					p.ConsumeN(1 + nameTokens) // '{%' tagname
 
 					for {
 						if p.Match(TokenSymbol, "%}") != nil {
 							// Okay, end the wrapping here
 							wrapper.Endtag = tagIdent.Val
+							wrapper.nodes = coalesceHTML(wrapper.nodes)
 							return wrapper, newParser(p.template.name, tagArgs, p.template), nil
 						}
 						t := p.Current()
@@ -255,8 +305,12 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, *Error) {
 		}
 
 		// Otherwise process next element to be wrapped
+		start, mark := p.idx, p.errorMark()
 		node, err := p.parseDocElement()
 		if err != nil {
+			if p.recoverFrom(start, mark, err) {
+				continue
+			}
 			return nil, nil, err
 		}
 		wrapper.nodes = append(wrapper.nodes, node)
@@ -266,12 +320,39 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, *Error) {
 		p.lastToken)
 }
 
+// ParseBodyUntil parses the body of a block tag until one of the given
+// terminator tags (which must not have any arguments) and returns the body
+// and the name of the terminator tag found. It's meant for custom block
+// tags: calling it repeatedly handles optional middle tags like this (for
+// {% mytag %}...{% else %}...{% endmytag %}):
+//
+//	body, endtag, err := doc.ParseBodyUntil("else", "endmytag")
+//	if err != nil {
+//		return nil, err
+//	}
+//	if endtag == "else" {
+//		elseBody, _, err = doc.ParseBodyUntil("endmytag")
+//		...
+//	}
+//
+// Use WrapUntilTag for terminator tags with arguments.
+func (p *Parser) ParseBodyUntil(names ...string) (*NodeWrapper, string, *Error) {
+	wrapper, args, err := p.WrapUntilTag(names...)
+	if err != nil {
+		return nil, "", err
+	}
+	if args.Remaining() > 0 {
+		return nil, "", args.Error(fmt.Sprintf("The %s-tag doesn't take any arguments.", wrapper.Endtag), nil)
+	}
+	return wrapper, wrapper.Endtag, nil
+}
+
 // Skips all nodes between starting tag and "{% endtag %}"
 func (p *Parser) SkipUntilTag(names ...string) *Error {
 	for p.Remaining() > 0 {
 		// New tag, check whether we have to stop wrapping here
 		if p.Peek(TokenSymbol, "{%") != nil {
-			tagIdent := p.PeekTypeN(1, TokenIdentifier)
+			tagIdent, nameTokens := p.peekQualifiedName(1)
 
 			if tagIdent != nil {
 				// We've found a (!) end-tag
@@ -287,7 +368,7 @@ func (p *Parser) SkipUntilTag(names ...string) *Error {
 				// We only process the tag if we've found an end tag
 				if found {
 					// Okay, endtag found.
-					p.ConsumeN(2) // '{%' tagname
+					p.ConsumeN(1 + nameTokens) // '{%' tagname
 
 					for {
 						if p.Match(TokenSymbol, "%}") != nil {
@@ -0,0 +1,128 @@
+package pongo2
+
+import (
+	"reflect"
+)
+
+// constantValue is an expression (made up of literals and pure filters only)
+// which got evaluated at parse time, like {{ 2 * 60 * 60 }}.
+type constantValue struct {
+	expr  IEvaluator // the original expression
+	value *Value
+}
+
+func (c *constantValue) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	writer.WriteString(c.value.String())
+	return nil
+}
+
+func (c *constantValue) GetPositionToken() *Token {
+	return c.expr.GetPositionToken()
+}
+
+func (c *constantValue) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	// Every render gets its own copy
+	v := *c.value
+	return &v, nil
+}
+
+func (c *constantValue) FilterApplied(name string) bool {
+	return c.expr.FilterApplied(name)
+}
+
+// foldConstants replaces the constant subexpressions of expr with their
+// values. Subexpressions failing to evaluate are kept, so the error is
+// reported while rendering (as usual).
+func (p *Parser) foldConstants(expr IEvaluator) IEvaluator {
+	if expr == nil {
+		return nil
+	}
+
+	constant := true
+	fold := func(sub IEvaluator) IEvaluator {
+		folded := p.foldConstants(sub)
+		if folded != nil && !isConstantExpression(folded) {
+			constant = false
+		}
+		return folded
+	}
+
+	switch e := expr.(type) {
+	case *constantValue:
+		return e
+	case *stringResolver, *intResolver, *floatResolver, *boolResolver:
+		// Already cheap to evaluate
+		return e
+	case *Expression:
+		e.expr1, e.expr2 = fold(e.expr1), fold(e.expr2)
+	case *conditionalExpression:
+		e.condition, e.trueExpr, e.falseExpr = fold(e.condition), fold(e.trueExpr), fold(e.falseExpr)
+	case *relationalExpression:
+		e.expr1, e.expr2 = fold(e.expr1), fold(e.expr2)
+		for i := range e.chainExprs {
+			e.chainExprs[i] = fold(e.chainExprs[i])
+		}
+	case *simpleExpression:
+		e.term1, e.term2 = fold(e.term1), fold(e.term2)
+	case *term:
+		e.factor1, e.factor2 = fold(e.factor1), fold(e.factor2)
+	case *power:
+		e.power1, e.power2 = fold(e.power1), fold(e.power2)
+	case *nodeFilteredVariable:
+		e.resolver = fold(e.resolver)
+		for _, filter := range e.filterChain {
+			if !filter.pure || (filter.parameter != nil && !isConstantExpression(filter.parameter)) {
+				constant = false
+			}
+			for _, parameter := range filter.moreParameters {
+				if !isConstantExpression(parameter) {
+					constant = false
+				}
+			}
+		}
+	default:
+		return expr
+	}
+
+	if !constant {
+		return expr
+	}
+	if value, ok := p.evaluateConstant(expr); ok {
+		return &constantValue{expr: expr, value: value}
+	}
+	return expr
+}
+
+func isConstantExpression(expr IEvaluator) bool {
+	switch expr.(type) {
+	case *constantValue, *stringResolver, *intResolver, *floatResolver, *boolResolver:
+		return true
+	}
+	return false
+}
+
+// evaluateConstant evaluates a constant expression; only simple results
+// (strings, numbers, booleans and nil) are used, so no render can modify the
+// value of another one.
+func (p *Parser) evaluateConstant(expr IEvaluator) (value *Value, ok bool) {
+	defer func() {
+		// e. g. an integer division by zero; it'll panic while rendering
+		if recover() != nil {
+			value, ok = nil, false
+		}
+	}()
+
+	value, err := expr.Evaluate(newExecutionContext(p.template, make(Context)))
+	if err != nil {
+		return nil, false
+	}
+
+	switch value.getResolvedValue().Kind() {
+	case reflect.Invalid, reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return value, true
+	}
+	return nil, false
+}
@@ -1,5 +1,9 @@
 package pongo2
 
+import (
+	"strings"
+)
+
 // Doc = { ( Filter | Tag | HTML ) }
 func (p *Parser) parseDocElement() (INode, *Error) {
 	t := p.Current()
@@ -11,6 +15,8 @@ func (p *Parser) parseDocElement() (INode, *Error) {
 		right := p.PeekTypeN(1, TokenSymbol)
 		n.trimLeft = left != nil && left.TrimWhitespaces
 		n.trimRight = right != nil && right.TrimWhitespaces
+		n.afterTag = left != nil && left.Val == "%}"
+		n.beforeTag = right != nil && right.Val == "{%"
 		p.Consume() // consume HTML element
 		return n, nil
 	case TokenSymbol:
@@ -41,6 +47,25 @@ func (tpl *Template) parse() *Error {
 		return err
 	}
 	tpl.root = doc
+
+	// Templates without any tags or variables don't need to be executed
+	if tpl.parent == nil && len(doc.Nodes) <= 1 {
+		var b strings.Builder
+		tpl.isStatic = true
+		for _, node := range doc.Nodes {
+			switch n := node.(type) {
+			case *nodeHTML:
+				// Verbatim content must be written as protected text
+				tpl.isStatic = tpl.isStatic && !n.token.verbatim
+				node.Execute(nil, &b)
+			case *nodeHTMLRun:
+				node.Execute(nil, &b)
+			default:
+				tpl.isStatic = false
+			}
+		}
+		tpl.staticText = b.String()
+	}
 	return nil
 }
 
@@ -48,12 +73,54 @@ func (p *Parser) parseDocument() (*nodeDocument, *Error) {
 	doc := &nodeDocument{}
 
 	for p.Remaining() > 0 {
+		start, mark := p.idx, p.errorMark()
 		node, err := p.parseDocElement()
 		if err != nil {
+			if p.recoverFrom(start, mark, err) {
+				continue
+			}
 			return nil, err
 		}
 		doc.Nodes = append(doc.Nodes, node)
 	}
+	doc.Nodes = coalesceHTML(doc.Nodes)
 
 	return doc, nil
 }
+
+// errorMark returns the number of collected errors (see recoverFrom).
+func (p *Parser) errorMark() int {
+	if p.template == nil {
+		return 0
+	}
+	return len(p.template.parseErrors)
+}
+
+// recoverFrom records the error of the element which started at token start
+// and skips the element (up to the end of its tag or variable), if the
+// template collects errors (see TemplateSet.ParseCollectErrors). Errors
+// collected since mark are dropped, because the tokens following the
+// element's start are parsed again. Returns false if errors aren't
+// collected.
+func (p *Parser) recoverFrom(start int, mark int, err *Error) bool {
+	if p.template == nil || !p.template.collectErrors {
+		return false
+	}
+	p.template.parseErrors = append(p.template.parseErrors[:mark], err.withPhase(PhaseParse))
+
+	closing := ""
+	switch t := p.Get(start); {
+	case t == nil:
+	case t.Typ == TokenSymbol && t.Val == "{{":
+		closing = "}}"
+	case t.Typ == TokenSymbol && t.Val == "{%":
+		closing = "%}"
+	}
+	p.idx = start + 1
+	if closing != "" {
+		for p.Remaining() > 0 && p.Match(TokenSymbol, closing) == nil {
+			p.Consume()
+		}
+	}
+	return true
+}
@@ -12,11 +12,23 @@ type Expression struct {
 	opToken *Token
 }
 
+type conditionalExpression struct {
+	trueExpr  IEvaluator
+	condition IEvaluator
+	falseExpr IEvaluator // optional
+}
+
 type relationalExpression struct {
 	// TODO: Add location token?
 	expr1   IEvaluator
 	expr2   IEvaluator
 	opToken *Token
+
+	// Further comparisons of a chain like 0 < x < 10 (evaluated like
+	// 0 < x and x < 10, but x only once); each operand is compared with
+	// the previous one
+	chainOps   []*Token
+	chainExprs []IEvaluator
 }
 
 type simpleExpression struct {
@@ -40,11 +52,30 @@ type power struct {
 	power2 IEvaluator
 }
 
+// listLiteral is a list like [1, "two", x]
+type listLiteral struct {
+	locationToken *Token
+	items         []IEvaluator
+}
+
+// mapLiteral is a map like {"a": 1, key: x}; the keys are strings (any
+// key expression is converted to a string).
+type mapLiteral struct {
+	locationToken *Token
+	keys          []IEvaluator
+	values        []IEvaluator
+}
+
 func (expr *Expression) FilterApplied(name string) bool {
 	return expr.expr1.FilterApplied(name) && (expr.expr2 == nil ||
 		(expr.expr2 != nil && expr.expr2.FilterApplied(name)))
 }
 
+func (expr *conditionalExpression) FilterApplied(name string) bool {
+	return expr.trueExpr.FilterApplied(name) && (expr.falseExpr == nil ||
+		(expr.falseExpr != nil && expr.falseExpr.FilterApplied(name)))
+}
+
 func (expr *relationalExpression) FilterApplied(name string) bool {
 	return expr.expr1.FilterApplied(name) && (expr.expr2 == nil ||
 		(expr.expr2 != nil && expr.expr2.FilterApplied(name)))
@@ -65,10 +96,22 @@ func (expr *power) FilterApplied(name string) bool {
 		(expr.power2 != nil && expr.power2.FilterApplied(name)))
 }
 
+func (l *listLiteral) FilterApplied(name string) bool {
+	return false
+}
+
+func (m *mapLiteral) FilterApplied(name string) bool {
+	return false
+}
+
 func (expr *Expression) GetPositionToken() *Token {
 	return expr.expr1.GetPositionToken()
 }
 
+func (expr *conditionalExpression) GetPositionToken() *Token {
+	return expr.trueExpr.GetPositionToken()
+}
+
 func (expr *relationalExpression) GetPositionToken() *Token {
 	return expr.expr1.GetPositionToken()
 }
@@ -85,6 +128,32 @@ func (expr *power) GetPositionToken() *Token {
 	return expr.power1.GetPositionToken()
 }
 
+func (l *listLiteral) GetPositionToken() *Token {
+	return l.locationToken
+}
+
+func (m *mapLiteral) GetPositionToken() *Token {
+	return m.locationToken
+}
+
+func (l *listLiteral) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := l.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (m *mapLiteral) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := m.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (expr *Expression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := expr.Evaluate(ctx)
 	if err != nil {
@@ -94,6 +163,15 @@ func (expr *Expression) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 	return nil
 }
 
+func (expr *conditionalExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (expr *relationalExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := expr.Evaluate(ctx)
 	if err != nil {
@@ -130,6 +208,34 @@ func (expr *power) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error
 	return nil
 }
 
+func (l *listLiteral) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	items := make([]interface{}, 0, len(l.items))
+	for _, item := range l.items {
+		value, err := item.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value.Interface())
+	}
+	return AsValue(items), nil
+}
+
+func (m *mapLiteral) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	items := make(map[string]interface{}, len(m.keys))
+	for i, keyExpr := range m.keys {
+		key, err := keyExpr.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		value, err := m.values[i].Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items[key.String()] = value.Interface()
+	}
+	return AsValue(items), nil
+}
+
 func (expr *Expression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	v1, err := expr.expr1.Evaluate(ctx)
 	if err != nil {
@@ -165,62 +271,101 @@ func (expr *Expression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	}
 }
 
+func (expr *conditionalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	cond, err := expr.condition.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Only the selected branch gets evaluated
+	if cond.IsTrue() {
+		return expr.trueExpr.Evaluate(ctx)
+	}
+	if expr.falseExpr != nil {
+		return expr.falseExpr.Evaluate(ctx)
+	}
+	return AsValue(nil), nil
+}
+
 func (expr *relationalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	v1, err := expr.expr1.Evaluate(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if expr.expr2 != nil {
-		v2, err := expr.expr2.Evaluate(ctx)
+	if expr.expr2 == nil {
+		return v1, nil
+	}
+	v2, err := expr.expr2.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := compareValues(ctx, expr.opToken, v1, v2)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range expr.chainOps {
+		if !result.IsTrue() {
+			// The remaining operands aren't evaluated anymore
+			return result, nil
+		}
+		v1 = v2
+		v2, err = expr.chainExprs[i].Evaluate(ctx)
 		if err != nil {
 			return nil, err
 		}
-		switch expr.opToken.Val {
-		case "<=":
-			if v1.IsFloat() || v2.IsFloat() {
-				return AsValue(v1.Float() <= v2.Float()), nil
-			}
-			if v1.IsTime() && v2.IsTime() {
-				tm1, tm2 := v1.Time(), v2.Time()
-				return AsValue(tm1.Before(tm2) || tm1.Equal(tm2)), nil
-			}
-			return AsValue(v1.Integer() <= v2.Integer()), nil
-		case ">=":
-			if v1.IsFloat() || v2.IsFloat() {
-				return AsValue(v1.Float() >= v2.Float()), nil
-			}
-			if v1.IsTime() && v2.IsTime() {
-				tm1, tm2 := v1.Time(), v2.Time()
-				return AsValue(tm1.After(tm2) || tm1.Equal(tm2)), nil
-			}
-			return AsValue(v1.Integer() >= v2.Integer()), nil
-		case "==":
-			return AsValue(v1.EqualValueTo(v2)), nil
-		case ">":
-			if v1.IsFloat() || v2.IsFloat() {
-				return AsValue(v1.Float() > v2.Float()), nil
-			}
-			if v1.IsTime() && v2.IsTime() {
-				return AsValue(v1.Time().After(v2.Time())), nil
-			}
-			return AsValue(v1.Integer() > v2.Integer()), nil
-		case "<":
-			if v1.IsFloat() || v2.IsFloat() {
-				return AsValue(v1.Float() < v2.Float()), nil
-			}
-			if v1.IsTime() && v2.IsTime() {
-				return AsValue(v1.Time().Before(v2.Time())), nil
-			}
-			return AsValue(v1.Integer() < v2.Integer()), nil
-		case "!=", "<>":
-			return AsValue(!v1.EqualValueTo(v2)), nil
-		case "in":
-			return AsValue(v2.Contains(v1)), nil
-		default:
-			return nil, ctx.Error(fmt.Sprintf("unimplemented: %s", expr.opToken.Val), expr.opToken)
+		result, err = compareValues(ctx, op, v1, v2)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		return v1, nil
+	}
+	return result, nil
+}
+
+// compareValues applies the relational operator op to v1 and v2.
+func compareValues(ctx *ExecutionContext, op *Token, v1, v2 *Value) (*Value, *Error) {
+	switch op.Val {
+	case "<=":
+		if v1.IsFloat() || v2.IsFloat() {
+			return AsValue(v1.Float() <= v2.Float()), nil
+		}
+		if v1.IsTime() && v2.IsTime() {
+			tm1, tm2 := v1.Time(), v2.Time()
+			return AsValue(tm1.Before(tm2) || tm1.Equal(tm2)), nil
+		}
+		return AsValue(v1.Integer() <= v2.Integer()), nil
+	case ">=":
+		if v1.IsFloat() || v2.IsFloat() {
+			return AsValue(v1.Float() >= v2.Float()), nil
+		}
+		if v1.IsTime() && v2.IsTime() {
+			tm1, tm2 := v1.Time(), v2.Time()
+			return AsValue(tm1.After(tm2) || tm1.Equal(tm2)), nil
+		}
+		return AsValue(v1.Integer() >= v2.Integer()), nil
+	case "==":
+		return AsValue(v1.EqualValueTo(v2)), nil
+	case ">":
+		if v1.IsFloat() || v2.IsFloat() {
+			return AsValue(v1.Float() > v2.Float()), nil
+		}
+		if v1.IsTime() && v2.IsTime() {
+			return AsValue(v1.Time().After(v2.Time())), nil
+		}
+		return AsValue(v1.Integer() > v2.Integer()), nil
+	case "<":
+		if v1.IsFloat() || v2.IsFloat() {
+			return AsValue(v1.Float() < v2.Float()), nil
+		}
+		if v1.IsTime() && v2.IsTime() {
+			return AsValue(v1.Time().Before(v2.Time())), nil
+		}
+		return AsValue(v1.Integer() < v2.Integer()), nil
+	case "!=", "<>":
+		return AsValue(!v1.EqualValueTo(v2)), nil
+	case "in":
+		return AsValue(v2.Contains(v1)), nil
+	default:
+		return nil, ctx.Error(fmt.Sprintf("unimplemented: %s", op.Val), op)
 	}
 }
 
@@ -329,6 +474,60 @@ func (expr *power) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	return p1, nil
 }
 
+// ListLiteral = "[" [ Expression { "," Expression } [ "," ] ] "]"
+func (p *Parser) parseListLiteral() (IEvaluator, *Error) {
+	list := &listLiteral{locationToken: p.Current()}
+	p.Match(TokenSymbol, "[")
+
+	for p.Match(TokenSymbol, "]") == nil {
+		if p.Remaining() == 0 {
+			return nil, p.Error("Unexpected EOF, expected ']' to close the list.", p.lastToken)
+		}
+		item, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+
+		if p.Match(TokenSymbol, ",") == nil && p.Peek(TokenSymbol, "]") == nil {
+			return nil, p.Error("Expected ',' or ']' after a list item.", nil)
+		}
+	}
+
+	return list, nil
+}
+
+// MapLiteral = "{" [ Expression ":" Expression { "," Expression ":" Expression } [ "," ] ] "}"
+func (p *Parser) parseMapLiteral() (IEvaluator, *Error) {
+	m := &mapLiteral{locationToken: p.Current()}
+	p.Match(TokenSymbol, "{")
+
+	for p.Match(TokenSymbol, "}") == nil {
+		if p.Remaining() == 0 {
+			return nil, p.Error("Unexpected EOF, expected '}' to close the map.", p.lastToken)
+		}
+		key, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.Match(TokenSymbol, ":") == nil {
+			return nil, p.Error("Expected ':' after a map key.", nil)
+		}
+		value, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, value)
+
+		if p.Match(TokenSymbol, ",") == nil && p.Peek(TokenSymbol, "}") == nil {
+			return nil, p.Error("Expected ',' or '}' after a map item.", nil)
+		}
+	}
+
+	return m, nil
+}
+
 func (p *Parser) parseFactor() (IEvaluator, *Error) {
 	if p.Match(TokenSymbol, "(") != nil {
 		expr, err := p.ParseExpression()
@@ -453,6 +652,9 @@ func (p *Parser) parseSimpleExpression() (IEvaluator, *Error) {
 	return expr, nil
 }
 
+// relationalOperators are the comparison operators (which can be chained).
+var relationalOperators = []string{"==", "<=", ">=", "!=", "<>", ">", "<"}
+
 func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 	expr1, err := p.parseSimpleExpression()
 	if err != nil {
@@ -463,13 +665,23 @@ func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 		expr1: expr1,
 	}
 
-	if t := p.MatchOne(TokenSymbol, "==", "<=", ">=", "!=", "<>", ">", "<"); t != nil {
-		expr2, err := p.parseRelationalExpression()
+	if t := p.MatchOne(TokenSymbol, relationalOperators...); t != nil {
+		expr2, err := p.parseSimpleExpression()
 		if err != nil {
 			return nil, err
 		}
 		expr.opToken = t
 		expr.expr2 = expr2
+
+		// Chained comparisons (a < b <= c)
+		for t := p.MatchOne(TokenSymbol, relationalOperators...); t != nil; t = p.MatchOne(TokenSymbol, relationalOperators...) {
+			next, err := p.parseSimpleExpression()
+			if err != nil {
+				return nil, err
+			}
+			expr.chainOps = append(expr.chainOps, t)
+			expr.chainExprs = append(expr.chainExprs, next)
+		}
 	} else if t := p.MatchOne(TokenKeyword, "in"); t != nil {
 		expr2, err := p.parseSimpleExpression()
 		if err != nil {
@@ -487,7 +699,39 @@ func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 	return expr, nil
 }
 
+// ParseExpression = LogicalExpression [ "if" LogicalExpression [ "else" ParseExpression ] ]
 func (p *Parser) ParseExpression() (IEvaluator, *Error) {
+	expr, err := p.parseLogicalExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.MatchOne(TokenIdentifier, "if") == nil {
+		return p.foldConstants(expr), nil
+	}
+
+	cond, err := p.parseLogicalExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	condExpr := &conditionalExpression{
+		trueExpr:  expr,
+		condition: cond,
+	}
+
+	if p.MatchOne(TokenIdentifier, "else") != nil {
+		falseExpr, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		condExpr.falseExpr = falseExpr
+	}
+
+	return p.foldConstants(condExpr), nil
+}
+
+func (p *Parser) parseLogicalExpression() (IEvaluator, *Error) {
 	rexpr1, err := p.parseRelationalExpression()
 	if err != nil {
 		return nil, err
@@ -500,7 +744,7 @@ func (p *Parser) ParseExpression() (IEvaluator, *Error) {
 	if p.PeekOne(TokenSymbol, "&&", "||") != nil || p.PeekOne(TokenKeyword, "and", "or") != nil {
 		op := p.Current()
 		p.Consume()
-		expr2, err := p.ParseExpression()
+		expr2, err := p.parseLogicalExpression()
 		if err != nil {
 			return nil, err
 		}
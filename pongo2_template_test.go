@@ -115,10 +115,12 @@ var tplContext = pongo2.Context{
 		"nil":                      nil,
 		"uint":                     uint(8),
 		"float":                    float64(3.1415),
+		"negative":                 -2500000000,
 		"str":                      "string",
 		"chinese_hello_world":      "你好世界",
 		"bool_true":                true,
 		"bool_false":               false,
+		"br_text":                  "a <b><br>\r\nb<BR/> \nc\nd<br />\n\ne",
 		"newline_text": `this is a text
 with a new line in it`,
 		"long_text": `This is a simple text.
@@ -739,3 +741,49 @@ func BenchmarkExecuteBlocksWithoutSandbox(b *testing.B) {
 		}
 	}
 }
+
+var benchmarkStaticText = strings.Repeat("<p>Lorem ipsum dolor sit amet, consectetur adipisici elit.</p>{# comment #}\n", 500)
+
+func BenchmarkExecuteStatic(b *testing.B) {
+	tpl, err := pongo2.FromString(benchmarkStaticText)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(tplContext, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Same text, but executed as usual (because of the variable)
+func BenchmarkExecuteStaticWithVariable(b *testing.B) {
+	tpl, err := pongo2.FromString(benchmarkStaticText + "{{ number }}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(tplContext, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The expressions are evaluated at parse time
+func BenchmarkExecuteConstantExpressions(b *testing.B) {
+	tpl, err := pongo2.FromString(`{{ 2 * 60 * 60 }} {{ "Pongo2"|lower|capfirst }} {{ 10 > 5 and 2 ^ 10 == 1024 }}`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(tplContext, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
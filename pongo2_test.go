@@ -1,7 +1,21 @@
 package pongo2_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/flosch/pongo2/v4"
 	. "gopkg.in/check.v1"
@@ -31,6 +45,14 @@ func parseTemplate(s string, c pongo2.Context) string {
 	return out
 }
 
+// uniqueName suffixes the names of globally registered test filters and
+// tags, so they don't collide if the tests run several times (-count).
+func uniqueName(name string) string {
+	return fmt.Sprintf("%s_%d", name, atomic.AddInt64(&uniqueNameCounter, 1))
+}
+
+var uniqueNameCounter int64
+
 func parseTemplateFn(s string, c pongo2.Context) func() {
 	return func() {
 		parseTemplate(s, c)
@@ -52,6 +74,9 @@ func (s *TestSuite) TestMisc(c *C) {
 	// Registers
 	c.Check(pongo2.RegisterFilter("escape", nil).Error(), Matches, ".*is already registered")
 	c.Check(pongo2.RegisterTag("for", nil).Error(), Matches, ".*is already registered")
+	c.Check(pongo2.TryRegisterFilter("upper", nil), ErrorMatches, "filter with name 'upper' is already registered")
+	c.Check(pongo2.TryRegisterTag("if", nil), ErrorMatches, "tag with name 'if' is already registered")
+	c.Check(parseTemplate("{{ \"a\"|upper }}{% if true %}b{% endif %}", nil), Equals, "Ab")
 
 	// ApplyFilter
 	v, err := pongo2.ApplyFilter("title", pongo2.AsValue("this is a title"), nil)
@@ -67,6 +92,82 @@ func (s *TestSuite) TestMisc(c *C) {
 	}, PanicMatches, `\[Error \(where: applyfilter\)\] Filter with name 'doesnotexist' not found.`)
 }
 
+func (s *TestSuite) TestMustExecute(c *C) {
+	tpl := pongo2.Must(testSuite2.FromString("{{ greeting }} {{ fail() }}"))
+
+	c.Check(tpl.MustExecute(pongo2.Context{"greeting": "hi", "fail": func() string { return "ok" }}), Equals, "hi ok")
+
+	ctx := pongo2.Context{"fail": func() (string, error) { return "", errors.New("render failed") }}
+	c.Check(func() { tpl.MustExecute(ctx) }, PanicMatches, `\[Error \(where: execution\) in <string> \| Line 1 Col 19 near 'fail'\] render failed`)
+	var buf bytes.Buffer
+	c.Check(func() { tpl.MustExecuteWriter(ctx, &buf) }, PanicMatches, `.*render failed`)
+	c.Check(buf.Len(), Equals, 0)
+}
+
+func (s *TestSuite) TestContextValidate(c *C) {
+	c.Check(pongo2.Context{"user": "flo", "items": []int{1}, "nothing": nil}.Validate(), IsNil)
+	c.Check(pongo2.Context{"bad-key": 1}.Validate(), ErrorMatches,
+		`\[Error \(where: checkForValidIdentifiers\)\] context-key 'bad-key' \(value: '1'\) is not a valid identifier`)
+	c.Check(pongo2.Context{"events": make(chan int)}.Validate(), ErrorMatches,
+		`\[Error \(where: validate\)\] context-key 'events' has a value of unsupported type chan`)
+}
+
+type tagCounterNode struct {
+	report bool
+}
+
+func (node *tagCounterNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	count, _ := ctx.GetShared("test_counter")
+	n, _ := count.(int)
+	if node.report {
+		writer.WriteString(fmt.Sprintf("%d", n))
+		return nil
+	}
+	ctx.SetShared("test_counter", n+1)
+	ctx.SetPrivate("counted", n+1)
+	return nil
+}
+
+func (s *TestSuite) TestSharedContextHelpers(c *C) {
+	set := pongo2.NewSet("shared helpers", pongo2.MustNewLocalFileSystemLoader(""))
+	set.RegisterTag("count", func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+		return &tagCounterNode{}, nil
+	})
+	set.RegisterTag("report", func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+		return &tagCounterNode{report: true}, nil
+	})
+
+	// Shared values survive the loop's scope, private ones don't
+	tpl := pongo2.Must(set.FromString("{% for i in items %}{% count %}{{ counted }}{% endfor %} {% report %} '{{ counted }}'"))
+	c.Check(tpl.MustExecute(pongo2.Context{"items": []int{1, 2, 3}}), Equals, "123 3 ''")
+}
+
+type jsonPriority int
+
+func (p jsonPriority) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"P%d"`, int(p))), nil
+}
+
+func (s *TestSuite) TestTojson(c *C) {
+	type event struct {
+		Name     string
+		At       time.Time
+		Priority jsonPriority
+	}
+	out := parseTemplate("{{ ev|tojson }} {{ html|tojson }} {{ safe|tojson }}", pongo2.Context{
+		"ev":   event{Name: "<launch>", At: time.Date(2014, 6, 10, 15, 30, 15, 0, time.UTC), Priority: 2},
+		"html": template.HTML("<b>bold</b>"),
+		"safe": pongo2.AsSafeValue("it's"),
+	})
+	c.Check(out, Equals, `{"Name":"\u003claunch\u003e","At":"2014-06-10T15:30:15Z","Priority":"P2"} "\u003cb\u003ebold\u003c/b\u003e" "it\u0027s"`)
+
+	type node struct{ Next *node }
+	cyclic := &node{}
+	cyclic.Next = cyclic
+	_, err := pongo2.Must(testSuite2.FromString("{{ n|tojson }}")).Execute(pongo2.Context{"n": cyclic})
+	c.Check(err, ErrorMatches, ".*where: filter:tojson.*encountered a cycle.*")
+}
+
 func (s *TestSuite) TestImplicitExecCtx(c *C) {
 	tpl, err := pongo2.FromString("{{ ImplicitExec }}")
 	if err != nil {
@@ -101,3 +202,1163 @@ func (s *TestSuite) TestImplicitExecCtx(c *C) {
 
 	c.Check(res, Equals, val)
 }
+
+func (s *TestSuite) TestIsolatedExecutionContext(c *C) {
+	var isolated *pongo2.ExecutionContext
+	out := parseTemplate("{% for x in items %}{{ isolate() }}{% endfor %}", pongo2.Context{
+		"secret": "parent data",
+		"items":  []int{1},
+		"isolate": func(ctx *pongo2.ExecutionContext) string {
+			isolated = pongo2.NewIsolatedExecutionContext(ctx, pongo2.Context{"visible": true})
+			isolated.Shared["from_child"] = 1
+			_, hasShared := ctx.Shared["from_child"]
+			return fmt.Sprintf("%v", hasShared)
+		},
+	})
+	c.Check(out, Equals, "true")
+	c.Check(isolated.Public, DeepEquals, pongo2.Context{"visible": true})
+	c.Check(isolated.Private["secret"], IsNil)
+	c.Check(isolated.Private["x"], IsNil)
+	c.Check(isolated.Private["pongo2"], NotNil)
+}
+
+func (s *TestSuite) TestValueComparison(c *C) {
+	// Equality with numeric promotion
+	c.Check(pongo2.AsValue(4).EqualValueTo(pongo2.AsValue(4.0)), Equals, true)
+	c.Check(pongo2.AsValue(uint(4)).EqualValueTo(pongo2.AsValue(4)), Equals, true)
+	c.Check(pongo2.AsValue(4.5).EqualValueTo(pongo2.AsValue(4)), Equals, false)
+	c.Check(pongo2.AsValue(nil).EqualValueTo(pongo2.AsValue(nil)), Equals, true)
+
+	// Ordering
+	cmp, err := pongo2.AsValue(3).CompareTo(pongo2.AsValue(3.5))
+	c.Check(err, IsNil)
+	c.Check(cmp, Equals, -1)
+	cmp, err = pongo2.AsValue("b").CompareTo(pongo2.AsValue("a"))
+	c.Check(err, IsNil)
+	c.Check(cmp, Equals, 1)
+	cmp, err = pongo2.AsValue(nil).CompareTo(pongo2.AsValue(nil))
+	c.Check(err, IsNil)
+	c.Check(cmp, Equals, 0)
+	_, err = pongo2.AsValue("a").CompareTo(pongo2.AsValue(1))
+	c.Check(err, NotNil)
+
+	// Containment
+	c.Check(pongo2.AsValue("hello world").Contains(pongo2.AsValue("o w")), Equals, true)
+	c.Check(pongo2.AsValue("hello world").Contains(pongo2.AsValue("xyz")), Equals, false)
+	c.Check(pongo2.AsValue([]float64{1.0, 2.0}).Contains(pongo2.AsValue(2)), Equals, true)
+}
+
+func (s *TestSuite) TestAccumulatorsKeepContextData(c *C) {
+	items := []string{"a", "b"}
+	count := 5
+	out := parseTemplate("{% append items \"c\" %}{% increment count %}{{ items|join:\",\" }} {{ count }}",
+		pongo2.Context{"items": items, "count": count})
+	c.Check(out, Equals, "a,b,c 6")
+	c.Check(items, DeepEquals, []string{"a", "b"})
+	c.Check(count, Equals, 5)
+}
+
+func (s *TestSuite) TestSafeKeys(c *C) {
+	set := pongo2.NewSet("safe keys", pongo2.MustNewLocalFileSystemLoader(""))
+	set.SetSafeKeys("body_html")
+
+	tpl, err := set.FromString("{{ body_html }} {{ title }} {{ body_html|upper }}{% for x in items %} {{ x }}{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{
+		"body_html": "<p>trusted</p>",
+		"title":     "<b>untrusted</b>",
+		"items":     []string{"<i>"},
+	})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, "<p>trusted</p> &lt;b&gt;untrusted&lt;/b&gt; &lt;P&gt;TRUSTED&lt;/P&gt; &lt;i&gt;")
+}
+
+func (s *TestSuite) TestConcurrentRegistration(c *C) {
+	tpl := pongo2.Must(testSuite2.FromString(`{% if true %}{{ "<b>"|lower }}{% endif %}`))
+
+	filterNames, tagNames := make([]string, 10), make([]string, 10)
+	for i := range filterNames {
+		filterNames[i], tagNames[i] = uniqueName("concurrent_filter"), uniqueName("concurrent_tag")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c.Check(pongo2.RegisterFilter(filterNames[i], func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+				return in, nil
+			}), IsNil)
+			c.Check(pongo2.RegisterTag(tagNames[i], tagSandboxDemoTagParser), IsNil)
+		}(i)
+		go func() {
+			defer wg.Done()
+			// Parse in a separate set (sets themselves aren't meant for concurrent parsing)
+			set := pongo2.NewSet("concurrent", pongo2.MustNewLocalFileSystemLoader(""))
+			_, err := set.FromString(`{% if true %}{{ "a"|upper }}{% endif %}`)
+			c.Check(err, IsNil)
+
+			out, err := tpl.Execute(nil)
+			c.Check(err, IsNil)
+			c.Check(out, Equals, "&lt;b&gt;")
+		}()
+	}
+	wg.Wait()
+
+	c.Check(parseTemplate(fmt.Sprintf(`{{ "x"|%s }}{%% %s %%}`, filterNames[3], tagNames[7]), nil), Equals, "xhello")
+}
+
+func (s *TestSuite) TestSetLocalRegistry(c *C) {
+	loud := pongo2.NewSet("loud", pongo2.MustNewLocalFileSystemLoader(""))
+	quiet := pongo2.NewSet("quiet", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Assert(loud.RegisterFilter("shout", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(in.String() + "!"), nil
+	}), IsNil)
+	c.Assert(quiet.RegisterFilter("shout", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(in.String() + "."), nil
+	}), IsNil)
+	c.Check(quiet.RegisterFilter("shout", nil), ErrorMatches, "filter with name 'shout' is already registered in set 'quiet'")
+
+	// Set-local filters shadow global ones
+	c.Assert(loud.RegisterFilter("upper", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue("UP"), nil
+	}), IsNil)
+
+	// Set-local tags
+	c.Assert(quiet.RegisterTag("greet", tagSandboxDemoTagParser), IsNil)
+
+	src := `{{ "hi"|shout }} {% filter shout %}ho{% endfilter %} {{ "x"|upper }}`
+	c.Check(pongo2.Must(loud.FromString(src)).MustExecute(nil), Equals, "hi! ho! UP")
+	c.Check(pongo2.Must(quiet.FromString(src)).MustExecute(nil), Equals, "hi. ho. X")
+	c.Check(pongo2.Must(quiet.FromString("{% greet %}")).MustExecute(nil), Equals, "hello")
+
+	// The global registry stays untouched
+	_, err := testSuite2.FromString(`{{ "hi"|shout }}`)
+	c.Check(err, ErrorMatches, ".*Filter 'shout' does not exist.")
+	_, err = loud.FromString("{% greet %}")
+	c.Check(err, ErrorMatches, ".*Tag 'greet' not found.*")
+}
+
+func (s *TestSuite) TestStrictFilterArguments(c *C) {
+	set := pongo2.NewSet("strict filters", pongo2.MustNewLocalFileSystemLoader(""))
+
+	// Lenient by default
+	tpl, err := set.FromString(`{{ "hello"|truncatechars }}`)
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "")
+
+	set.Options.StrictFilterArguments = true
+	_, err = set.FromString(`{{ "hello"|truncatechars }}`)
+	c.Check(err, ErrorMatches, `.*Line 1 Col 12 near 'truncatechars'\] Filter 'truncatechars' requires an argument.`)
+	_, err = set.FromString(`{% filter upper:"x" %}hello{% endfilter %}`)
+	c.Check(err, ErrorMatches, `.*Filter 'upper' does not take an argument.`)
+
+	tpl, err = set.FromString(`{{ "hello"|truncatechars:4 }} {{ "a,b"|split:","|join }}`)
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "h... ab")
+}
+
+func (s *TestSuite) TestTemplateFields(c *C) {
+	tpl := pongo2.Must(testSuite2.FromString(`{% for item in items %}{{ item.name }} {{ forloop.Counter }}{% endfor %}
+{% set total = items|length %}{{ total }} {{ user.name|default:fallback }}
+{% with title=page.title %}{{ title }}{% endwith %}
+{% macro greet(who) %}{{ who }} {{ greeting }}{% endmacro %}{{ greet(user) }}
+{{ "on" if flag }} {{ pongo2.version }}
+{% for item in items %}{% include "template_tests/error_stack.helper" %}{% endfor %}`))
+	c.Check(tpl.Fields(), DeepEquals, []string{"fail", "fallback", "flag", "greeting", "items", "page", "user"})
+
+	// Blocks of the whole inheritance chain
+	tpl = pongo2.Must(testSuite2.FromString(`{% extends "template_tests/inheritance/base.tpl" %}{% block content %}{{ block.super }}{{ body }}{% endblock %}`))
+	c.Check(tpl.Fields(), DeepEquals, []string{"body"})
+}
+
+func (s *TestSuite) TestInjectMeta(c *C) {
+	set := pongo2.NewSet("meta", pongo2.MustNewLocalFileSystemLoader(""))
+	src := "'{{ pongo2.version }}'{% for i in items %} '{{ pongo2.version }}'{% endfor %}"
+	ctx := pongo2.Context{"items": []int{1}}
+
+	c.Check(pongo2.Must(set.FromString(src)).MustExecute(ctx), Equals, fmt.Sprintf("'%s' '%s'", pongo2.Version, pongo2.Version))
+
+	set.SetInjectMeta(false)
+	c.Check(pongo2.Must(set.FromString(src)).MustExecute(ctx), Equals, "'' ''")
+}
+
+func (s *TestSuite) TestEscapeMode(c *C) {
+	ctx := pongo2.Context{
+		"plain":  "<b>plain</b>",
+		"marked": pongo2.AsUnsafeValue("<b>marked</b>"),
+	}
+	src := "{{ plain }} {{ marked }}{% autoescape off %} {{ marked }}{% endautoescape %}"
+
+	tests := []struct {
+		mode     pongo2.EscapeMode
+		expected string
+	}{
+		{pongo2.ModeEscapeAll, "&lt;b&gt;plain&lt;/b&gt; &lt;b&gt;marked&lt;/b&gt; <b>marked</b>"},
+		{pongo2.ModeEscapeNone, "<b>plain</b> <b>marked</b> <b>marked</b>"},
+		{pongo2.ModeEscapeMarked, "<b>plain</b> &lt;b&gt;marked&lt;/b&gt; <b>marked</b>"},
+	}
+	for _, test := range tests {
+		set := pongo2.NewSet("escape mode", pongo2.MustNewLocalFileSystemLoader(""))
+		set.SetEscapeMode(test.mode)
+		out, err := pongo2.Must(set.FromString(src)).Execute(ctx)
+		if err != nil {
+			c.Fatal(err)
+		}
+		c.Check(out, Equals, test.expected)
+	}
+}
+
+func (s *TestSuite) TestErrorStack(c *C) {
+	tpl, err := testSuite2.FromString(`{% block list %}{% for item in items %}{% include "template_tests/error_stack.helper" %}{% endfor %}{% endblock %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(pongo2.Context{
+		"items": []int{1, 2},
+		"fail": func(item int) (string, error) {
+			if item == 2 {
+				return "", errors.New("item 2 is broken")
+			}
+			return "ok", nil
+		},
+	})
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Matches, ".*item 2 is broken")
+
+	stack := err.(*pongo2.Error).Stack()
+	c.Assert(stack, HasLen, 3)
+	c.Check(stack[0], Equals, "block 'list'")
+	c.Check(stack[1], Matches, `include '.*template_tests[/\\]error_stack\.helper'`)
+	c.Check(stack[2], Equals, "macro 'render_item'")
+}
+
+func (s *TestSuite) TestTemplateLimits(c *C) {
+	set := pongo2.NewSet("limits", pongo2.MustNewLocalFileSystemLoader(""))
+	set.SetMaxIncludeDepth(5)
+
+	// Recursive (lazy) include chain
+	tpl, err := set.FromFile("template_tests/include_recursive.helper")
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(pongo2.Context{"name": "include_recursive.helper"})
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Matches, `\[Error \(where: limits\) in .*include_recursive\.helper\] maximum include depth of 5 exceeded`)
+	c.Check(err.(*pongo2.Error).Stack(), HasLen, 5)
+
+	// Includes within the limit
+	tpl, err = set.FromFile("template_tests/include_depth_1.helper")
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(nil)
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "12")
+
+	set.SetMaxIncludeDepth(0)
+	set.SetMaxTemplateSize(16)
+	_, err = set.FromFile("template_tests/include_depth_1.helper")
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Matches, `.*template exceeds the maximum size of 16 bytes`)
+	_, err = set.FromFile("template_tests/include_depth_2.helper")
+	c.Check(err, IsNil)
+}
+
+func (s *TestSuite) TestRenderTimeout(c *C) {
+	set := pongo2.NewSet("render timeout", pongo2.MustNewLocalFileSystemLoader(""))
+	set.SetRenderTimeout(20 * time.Millisecond)
+
+	// Rendering all items takes at least 2s (100 times the timeout), so the
+	// check doesn't depend on the speed of the machine
+	items := make([]int, 1000)
+	calls := 0
+	ctx := pongo2.Context{
+		"items": items,
+		"slow":  func() string { calls++; time.Sleep(2 * time.Millisecond); return "." },
+	}
+	tpl := pongo2.Must(set.FromString("{% for i in items %}{{ slow() }}{% endfor %}"))
+	_, err := tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, `\[Error \(where: timeout\) in <string>\] rendering exceeded the timeout of 20ms`)
+	c.Check(calls < len(items), Equals, true, Commentf("calls: %d", calls))
+
+	// Fast templates are unaffected
+	out, err := tpl.Execute(pongo2.Context{"items": items[:3], "slow": func() string { return "." }})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "...")
+}
+
+func (s *TestSuite) TestCyclicTemplates(c *C) {
+	_, err := testSuite2.FromFile("template_tests/include_cycle_a.helper")
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Matches, `\[Error \(where: cyclecheck\) in .*\] cyclic template reference: `+
+		`.*include_cycle_a\.helper -> .*include_cycle_b\.helper -> .*include_cycle_a\.helper`)
+
+	_, err = testSuite2.FromFile("template_tests/extends_self.helper")
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Matches, `.*cyclic template reference: .*extends_self\.helper -> .*extends_self\.helper`)
+}
+
+func (s *TestSuite) TestIncludeFromSet(c *C) {
+	plugins := pongo2.NewSet("plugins", pongo2.MustNewLocalFileSystemLoader("template_tests/plugins"))
+	plugins.Globals["plugin_global"] = "from plugins"
+
+	set := pongo2.NewSet("main", pongo2.MustNewLocalFileSystemLoader(""))
+	set.Globals["main_global"] = "from main"
+	set.RegisterIncludeSet("plugins", plugins)
+
+	tpl, err := set.FromString(`{% for name in names %}{% include "widget.helper" from "plugins" %}; {% endfor %}` +
+		`{% include widget from "plugins" with name="lazy" %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{
+		"names":  []string{"a", "b"},
+		"widget": "widget.helper",
+	})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, "Widget A (from plugins, from main); Widget B (from plugins, from main); "+
+		"Widget LAZY (from plugins, from main)")
+
+	_, err = set.FromString(`{% include "widget.helper" from "unknown" %}`)
+	c.Check(err, ErrorMatches, `.*Template set 'unknown' is not registered for includes.`)
+}
+
+func (s *TestSuite) TestErrorPhase(c *C) {
+	_, err := pongo2.FromString("{{ 'unterminated }}")
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseLex)
+
+	_, err = pongo2.FromString("{% if true %}never closed")
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseParse)
+
+	_, err = pongo2.FromString("{{ value|unknown_filter }}")
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseParse)
+
+	tpl, err := pongo2.FromString(`{{ value|slice:"x" }}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(pongo2.Context{"value": "abc"})
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseExecute)
+	c.Check(err.(*pongo2.Error).Phase.String(), Equals, "execute")
+
+	_, err = tpl.Execute(pongo2.Context{"invalid-key": 1})
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseExecute)
+}
+
+func (s *TestSuite) TestIncludableTemplates(c *C) {
+	set := pongo2.NewSet("includable", pongo2.MustNewLocalFileSystemLoader("template_tests"))
+	set.SetIncludableTemplates("includes.helper")
+
+	tpl, err := set.FromString(`{% include widget %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(pongo2.Context{"widget": "includes.helper"})
+	c.Check(err, IsNil)
+
+	_, err = tpl.Execute(pongo2.Context{"widget": "macro.helper"})
+	c.Check(err, ErrorMatches, `.*Template 'macro.helper' is not includable.*`)
+
+	// Static includes aren't restricted
+	tpl, err = set.FromString(`{% include "macro.helper" %}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(nil)
+	c.Check(err, IsNil)
+
+	// Naming another set doesn't bypass the list
+	other := pongo2.NewSet("includable other", pongo2.NewInMemoryLoader(map[string]string{
+		"includes.helper": "allowed",
+		"secret.html":     "secret",
+	}))
+	set.RegisterIncludeSet("other", other)
+	tpl, err = set.FromString(`{% include widget from "other" %}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"widget": "includes.helper"})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "allowed")
+	_, err = tpl.Execute(pongo2.Context{"widget": "secret.html"})
+	c.Check(err, ErrorMatches, `.*Template 'secret.html' is not includable.*`)
+}
+
+type stringerValue struct{ name string }
+
+func (v stringerValue) String() string { return "<" + v.name + ">" }
+
+type textValue struct{ code int }
+
+func (v *textValue) MarshalText() ([]byte, error) { return []byte(fmt.Sprintf("code-%d", v.code)), nil }
+
+type colorValue int
+
+func (v colorValue) String() string { return [...]string{"red", "<green>"}[v] }
+
+func (s *TestSuite) TestStringerOutput(c *C) {
+	tpl, err := pongo2.FromString("{{ stringer }} {{ stringer_ptr }} {{ err }} {{ text }} {{ stringer|safe }}")
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{
+		"stringer":     stringerValue{"a"},
+		"stringer_ptr": &stringerValue{"b"},
+		"err":          errors.New("failed & aborted"),
+		"text":         &textValue{42},
+	})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "&lt;a&gt; &lt;b&gt; failed &amp; aborted code-42 <a>")
+
+	// Named scalar types use their methods as well
+	tpl, err = pongo2.FromString("{{ duration }} {{ color }} {{ color_ptr }} {{ plain }} {{ duration|add:1 }}")
+	c.Assert(err, IsNil)
+	green := colorValue(1)
+	out, err = tpl.Execute(pongo2.Context{
+		"duration":  90 * time.Second,
+		"color":     colorValue(0),
+		"color_ptr": &green,
+		"plain":     3,
+	})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "1m30s red &lt;green&gt; 3 90000000001")
+}
+
+func (s *TestSuite) TestExecuteWithGlobals(c *C) {
+	tpl, err := pongo2.FromString("{{ request_id }} {{ user }}")
+	c.Assert(err, IsNil)
+
+	globals := pongo2.Context{"request_id": "req-1", "user": "anonymous"}
+	var buf bytes.Buffer
+	err = tpl.ExecuteWithGlobals(pongo2.Context{"user": "flosch"}, globals, &buf)
+	c.Assert(err, IsNil)
+	c.Check(buf.String(), Equals, "req-1 flosch")
+
+	buf.Reset()
+	err = tpl.ExecuteWithGlobals(nil, globals, &buf)
+	c.Assert(err, IsNil)
+	c.Check(buf.String(), Equals, "req-1 anonymous")
+	c.Check(globals, DeepEquals, pongo2.Context{"request_id": "req-1", "user": "anonymous"})
+}
+
+func (s *TestSuite) TestDumpFilter(c *C) {
+	data := pongo2.Context{
+		"data": map[string]interface{}{
+			"name": "<pongo2>",
+			"tags": []string{"go", "templates"},
+			"user": &user{Name: "flosch"},
+		},
+	}
+
+	_, err := pongo2.NewSet("dump-nodebug", pongo2.DefaultLoader).FromString("{{ data|dump }}")
+	c.Check(err, ErrorMatches, `.*Filter 'dump' is only available in debug mode.*`)
+
+	set := pongo2.NewSet("dump", pongo2.DefaultLoader)
+	set.Debug = true
+	tpl, err := set.FromString("{{ data|dump }}")
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(data)
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, `<table class="pongo2-dump">`+
+		`<tr><th>name</th><td>&lt;pongo2&gt;</td></tr>`+
+		`<tr><th>tags</th><td><table class="pongo2-dump"><tr><th>0</th><td>go</td></tr><tr><th>1</th><td>templates</td></tr></table></td></tr>`+
+		`<tr><th>user</th><td><table class="pongo2-dump"><tr><th>Name</th><td>flosch</td></tr><tr><th>Validated</th><td>False</td></tr></table></td></tr>`+
+		`</table>`)
+
+	tpl, err = set.FromString("{{ data|dump:1 }}")
+	c.Assert(err, IsNil)
+	out, err = tpl.Execute(data)
+	c.Assert(err, IsNil)
+	c.Check(out, Matches, `<table class="pongo2-dump"><tr><th>name</th><td>&lt;pongo2&gt;</td></tr><tr><th>tags</th><td><em>...</em></td></tr>.*`)
+}
+
+func (s *TestSuite) TestFilterKwargs(c *C) {
+	wrap := uniqueName("test_wrap")
+	err := pongo2.RegisterFilterKwargs(wrap, func(in *pongo2.Value, kwargs map[string]*pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		before, after := "[", "]"
+		if v, has := kwargs["before"]; has {
+			before = v.String()
+		}
+		if v, has := kwargs["after"]; has {
+			after = v.String()
+		}
+		return pongo2.AsValue(before + in.String() + after), nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(pongo2.RegisterFilterKwargs(wrap, nil), NotNil)
+	c.Check(pongo2.FilterExists(wrap), Equals, true)
+
+	tpl, err := pongo2.FromString(fmt.Sprintf(`{{ name|%[1]s(after=suffix, before="<<") }} {{ name|%[1]s(before="(")|upper }} {{ name|%[1]s }}`, wrap))
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"name": "flosch", "suffix": ">>"})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "&lt;&lt;flosch&gt;&gt; (FLOSCH] [flosch]")
+
+	_, err = pongo2.FromString(fmt.Sprintf(`{{ name|%s:"x" }}`, wrap))
+	c.Check(err, ErrorMatches, `.*Filter 'test_wrap_\d+' only takes keyword arguments.*`)
+	_, err = pongo2.FromString(`{{ name|upper(x=1) }}`)
+	c.Check(err, ErrorMatches, `.*Filter 'upper' does not take keyword arguments.*`)
+	_, err = pongo2.FromString(fmt.Sprintf(`{{ name|%s(before="a", before="b") }}`, wrap))
+	c.Check(err, ErrorMatches, `.*Keyword argument 'before' given twice.*`)
+	_, err = pongo2.FromString(fmt.Sprintf(`{{ name|%s(before "a") }}`, wrap))
+	c.Check(err, ErrorMatches, `.*Expected '=' after the name of the keyword argument.*`)
+
+	tpl, err = pongo2.FromString(`{{ name|truncate(lenght=3) }}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(pongo2.Context{"name": "flosch"})
+	c.Check(err, ErrorMatches, `.*unknown keyword argument 'lenght'.*`)
+}
+
+func (s *TestSuite) TestSetCapture(c *C) {
+	calls := 0
+	tpl, err := pongo2.FromString(`{% set nav %}[{% include "template_tests/includes.helper" %}{{ count() }}]{% endset %}{{ nav }}{{ nav }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{
+		"what_am_i": "<nav>",
+		"count": func() int {
+			calls++
+			return calls
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "[I'm &lt;nav&gt;1][I'm &lt;nav&gt;1]")
+	c.Check(calls, Equals, 1)
+
+	_, err = pongo2.FromString(`{% set nav %}never closed`)
+	c.Check(err, NotNil)
+}
+
+func (s *TestSuite) TestNaturaltimeNow(c *C) {
+	tpl, err := pongo2.FromString("{{ past|naturaltime }} / {{ future|naturaltime }}")
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{
+		"past":   time.Now().Add(-2*time.Hour - time.Minute),
+		"future": time.Now().Add(50 * time.Hour),
+	})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "2 hours ago / in 2 days")
+}
+
+func (s *TestSuite) TestParseCollectErrors(c *C) {
+	tpl, errs := pongo2.DefaultSet.ParseCollectErrors("template_tests/parse_errors.helper")
+	c.Assert(tpl, NotNil)
+	c.Assert(errs, HasLen, 3)
+	c.Check(errs[0].Line, Equals, 2)
+	c.Check(errs[0].Error(), Matches, `.*Filter 'nonexistent_filter' does not exist.*`)
+	c.Check(errs[1].Line, Equals, 3)
+	c.Check(errs[1].Error(), Matches, `.*Filter 'nonexistent_filter2' does not exist.*`)
+	c.Check(errs[2].Line, Equals, 4)
+	c.Check(errs[2].Error(), Matches, `.*Tag 'unknowntag' not found.*`)
+	for _, err := range errs {
+		c.Check(err.Phase, Equals, pongo2.PhaseParse)
+	}
+
+	// The partial template renders everything but the erroneous elements
+	out, err := tpl.Execute(pongo2.Context{"a": 1, "b": 2})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "ok 1\n\nfine \n\n2\n")
+
+	tpl, errs = pongo2.DefaultSet.ParseCollectErrors("template_tests/if.tpl")
+	c.Check(tpl, NotNil)
+	c.Check(errs, HasLen, 0)
+
+	// FromFile still stops at the first error
+	_, err = pongo2.FromFile("template_tests/parse_errors.helper")
+	c.Check(err, ErrorMatches, `.*Filter 'nonexistent_filter' does not exist.*`)
+}
+
+func (s *TestSuite) TestValueLenIndex(c *C) {
+	list := pongo2.AsValue([]string{"a", "b", "c"})
+	c.Check(list.IsIterable(), Equals, true)
+	c.Check(list.Len(), Equals, 3)
+	c.Check(list.Index(0).String(), Equals, "a")
+	c.Check(list.Index(-1).String(), Equals, "c")
+	c.Check(list.Index(3).IsNil(), Equals, true)
+	c.Check(list.Index(-4).IsNil(), Equals, true)
+
+	str := pongo2.AsValue("你好世界")
+	c.Check(str.IsIterable(), Equals, true)
+	c.Check(str.Len(), Equals, 4)
+	c.Check(str.Index(1).String(), Equals, "好")
+	c.Check(str.Index(-1).String(), Equals, "界")
+	c.Check(str.Index(4).IsNil(), Equals, true)
+
+	c.Check(pongo2.AsValue(map[string]int{"a": 1}).IsIterable(), Equals, true)
+	c.Check(pongo2.AsValue(42).IsIterable(), Equals, false)
+	c.Check(pongo2.AsValue(42).Len(), Equals, 0)
+	c.Check(pongo2.AsValue(42).Index(0).IsNil(), Equals, true)
+}
+
+func (s *TestSuite) TestFilterWithContext(c *C) {
+	lookup := uniqueName("test_ctx_lookup")
+	err := pongo2.RegisterFilterWithContext(lookup, func(ctx *pongo2.ExecutionContext, in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(fmt.Sprintf("%s=%v", in.String(), ctx.Public[in.String()])), nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(pongo2.RegisterFilterWithContext(lookup, nil), NotNil)
+	c.Check(pongo2.RegisterFilter(lookup, nil), NotNil)
+	c.Check(pongo2.RegisterFilterWithContext("upper", nil), NotNil)
+	c.Check(pongo2.FilterExists(lookup), Equals, true)
+
+	tpl, err := pongo2.FromString(fmt.Sprintf(`{{ "user"|%[1]s|upper }} {%% filter %[1]s %%}user{%% endfilter %%}`, lookup))
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"user": "flosch"})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "USER=FLOSCH user=flosch")
+}
+
+func (s *TestSuite) TestRenderFilter(c *C) {
+	set := pongo2.NewSet("render", pongo2.DefaultLoader)
+	tpl, err := set.FromString(`<div>{{ snippet|render }}</div> {{ snippet }}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{
+		"snippet": "Hello <b>{{ name }}</b>{% if name %}!{% endif %}",
+		"name":    "<flosch>",
+	})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "<div>Hello <b>&lt;flosch&gt;</b>!</div> Hello &lt;b&gt;{{ name }}&lt;/b&gt;{% if name %}!{% endif %}")
+
+	// Snippets rendering themselves
+	_, err = tpl.Execute(pongo2.Context{"snippet": "{{ snippet|render }}"})
+	c.Check(err, ErrorMatches, `.*maximum render depth of 10 exceeded.*`)
+
+	_, err = tpl.Execute(pongo2.Context{"snippet": "{{ name|nonexistent }}"})
+	c.Check(err, ErrorMatches, `.*Filter 'nonexistent' does not exist.*`)
+
+	set.SetRenderFilterEnabled(false)
+	_, err = tpl.Execute(pongo2.Context{"snippet": "{{ name }}"})
+	c.Check(err, ErrorMatches, `.*the render filter is disabled for this template set.*`)
+}
+
+func (s *TestSuite) TestStaticTemplates(c *C) {
+	text := strings.Repeat("<p>Static {text}\n</p>", 10)
+
+	// Text only
+	tpl, err := pongo2.FromString(text + "{# comment #}" + text)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"name": "flosch"})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, text+text)
+	_, err = tpl.Execute(pongo2.Context{"invalid-key": true})
+	c.Check(err, ErrorMatches, `.*context-key 'invalid-key' .* is not a valid identifier.*`)
+
+	// Joined text within tags and with trim options
+	tpl, err = pongo2.FromString("A{# 1 #}B {# 2 #} {% if true %}\n  C{# 3 #}D\n  {% endif %}E")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "AB  \n  CD\n  E")
+	tpl.Options.TrimBlocks = true
+	tpl.Options.LStripBlocks = true
+	c.Check(tpl.MustExecute(nil), Equals, "AB   CD\nE")
+}
+
+func (s *TestSuite) TestConstantFolding(c *C) {
+	tests := []struct {
+		tpl, out string
+	}{
+		{`{{ 2 * 60 * 60 }}`, "7200"},
+		{`{{ -(1 + 2) ^ 2 }}`, "-9.000000"},
+		{`{{ 10 / 4.0 }} {{ 7 % 3 }}`, "2.500000 1"},
+		{`{{ "a" == "a" and 1 < 2 }}`, "True"},
+		{`{{ "yes" if 1 > 2 else "no" }}`, "no"},
+		{`{{ "pongo2"|upper|truncatechars:4 }}`, "P..."},
+		{`{{ "<b>"|lower }} {{ "<b>"|safe }} {{ "<b>"|escape|safe }}`, "&lt;b&gt; <b> &lt;b&gt;"},
+		{`{{ number * (2 * 60) }} {{ "x"|upper|add:name }}`, "2520 Xflosch"},
+	}
+	for _, test := range tests {
+		tpl, err := pongo2.FromString(test.tpl)
+		c.Assert(err, IsNil)
+		for i := 0; i < 2; i++ {
+			out, err := tpl.Execute(pongo2.Context{"number": 21, "name": "flosch"})
+			c.Assert(err, IsNil)
+			c.Check(out, Equals, test.out, Commentf("template: %s", test.tpl))
+		}
+	}
+
+	// Errors are still reported while rendering
+	tpl, err := pongo2.FromString(`{{ -"abc" }}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, `.*Negative sign on a non-number expression.*`)
+}
+
+var errTestSentinel = errors.New("sentinel error")
+
+type sentinelLoader struct{}
+
+func (sentinelLoader) Abs(base, name string) string { return name }
+
+func (sentinelLoader) Get(path string) (io.Reader, error) {
+	return nil, fmt.Errorf("loading '%s': %w", path, errTestSentinel)
+}
+
+func (s *TestSuite) TestErrorUnwrap(c *C) {
+	filterSet := pongo2.NewSet("unwrap", pongo2.MustNewLocalFileSystemLoader(""))
+	err := filterSet.RegisterFilter("test_sentinel", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return nil, &pongo2.Error{Sender: "filter:test_sentinel", OrigError: errTestSentinel}
+	})
+	c.Assert(err, IsNil)
+
+	ctx := pongo2.Context{
+		"fail": func() (string, error) { return "", errTestSentinel },
+	}
+	for _, src := range []string{
+		`{{ "x"|test_sentinel }}`,
+		`{% filter test_sentinel %}x{% endfilter %}`,
+		`{{ fail() }}`,
+		`{{ "x"|upper|test_sentinel|lower }}`,
+	} {
+		tpl, err := filterSet.FromString(src)
+		c.Assert(err, IsNil)
+		_, err = tpl.Execute(ctx)
+		c.Check(errors.Is(err, errTestSentinel), Equals, true, Commentf("template: %s", src))
+
+		var tplErr *pongo2.Error
+		c.Check(errors.As(err, &tplErr), Equals, true)
+	}
+
+	// Errors of template loaders
+	set := pongo2.NewSet("sentinel", sentinelLoader{})
+	_, err = set.FromFile("missing.tpl")
+	c.Check(errors.Is(err, errTestSentinel), Equals, true)
+	c.Check(err, ErrorMatches, `.*unable to resolve template: loading 'missing.tpl': sentinel error.*`)
+
+	// Errors while parsing
+	_, err = pongo2.FromString("{{ 99999999999999999999 }}")
+	var numErr *strconv.NumError
+	c.Check(errors.As(err, &numErr), Equals, true)
+}
+
+func (s *TestSuite) TestJSONCanonicalFilter(c *C) {
+	type item struct {
+		Zeta  int
+		Alpha map[string]interface{}
+	}
+
+	// Same data, inserted in different orders
+	a := map[string]interface{}{}
+	a["b"] = []interface{}{1, map[string]interface{}{"y": 2, "x": 1}}
+	a["a"] = item{Zeta: 1, Alpha: map[string]interface{}{"n": nil, "m": 12345678901234567}}
+	b := map[string]interface{}{}
+	b["a"] = item{Alpha: map[string]interface{}{"m": 12345678901234567, "n": nil}, Zeta: 1}
+	b["b"] = []interface{}{1, map[string]interface{}{"x": 1, "y": 2}}
+
+	outA := pongo2.MustApplyFilter("json_canonical", pongo2.AsValue(a), nil).String()
+	outB := pongo2.MustApplyFilter("json_canonical", pongo2.AsValue(b), nil).String()
+	c.Check(outA, Equals, `{"a":{"Alpha":{"m":12345678901234567,"n":null},"Zeta":1},"b":[1,{"x":1,"y":2}]}`)
+	c.Check(outB, Equals, outA)
+
+	_, err := pongo2.ApplyFilter("json_canonical", pongo2.AsValue(make(chan int)), nil)
+	c.Check(err, ErrorMatches, `.*where: filter:json_canonical.*unsupported type.*`)
+}
+
+func (s *TestSuite) TestHTTPLoader(c *C) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path]++
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/tpl/page.tpl":
+			io.WriteString(w, `Page: {% include "parts/widget.tpl" %}`)
+		case "/tpl/parts/widget.tpl":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			io.WriteString(w, "[widget]")
+		case "/tpl/broken.tpl":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	loader, err := pongo2.NewHTTPLoader(server.URL + "/tpl/")
+	c.Assert(err, IsNil)
+	loader.SetClient(server.Client())
+
+	// Without the header
+	_, err = loader.Get(loader.Abs("", "page.tpl"))
+	c.Check(errors.Is(err, os.ErrPermission), Equals, true)
+	loader.SetHeader("X-Token", "secret")
+
+	set := pongo2.NewSet("http", loader)
+	tpl, err := set.FromFile("page.tpl")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "Page: [widget]")
+
+	widget := loader.Abs(server.URL+"/tpl/page.tpl", "parts/widget.tpl")
+	c.Check(widget, Equals, server.URL+"/tpl/parts/widget.tpl")
+	c.Check(loader.Abs(widget, "/other.tpl"), Equals, server.URL+"/other.tpl")
+	c.Check(loader.Abs(widget, "http://example.com/x.tpl"), Equals, "http://example.com/x.tpl")
+
+	// Other hosts never get the headers
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request to another host (X-Token: %q)", r.Header.Get("X-Token"))
+	}))
+	defer other.Close()
+	_, err = loader.Get(loader.Abs(widget, other.URL+"/x.tpl"))
+	c.Check(errors.Is(err, os.ErrPermission), Equals, true)
+	_, err = set.FromString(`{% include "` + other.URL + `/x.tpl" %}`)
+	c.Check(err, ErrorMatches, ".*not on the host of the base URL.*")
+
+	// Revalidated using the ETag
+	for i := 0; i < 2; i++ {
+		r, err := loader.Get(widget)
+		c.Assert(err, IsNil)
+		content, _ := ioutil.ReadAll(r)
+		c.Check(string(content), Equals, "[widget]")
+	}
+	c.Check(requests["/tpl/parts/widget.tpl"], Equals, 3)
+	c.Check(notModified, Equals, 2)
+
+	// Used without a request within the TTL
+	loader.SetTTL(time.Hour)
+	_, err = loader.Get(widget)
+	c.Assert(err, IsNil)
+	c.Check(requests["/tpl/parts/widget.tpl"], Equals, 3)
+
+	_, err = set.FromFile("missing.tpl")
+	c.Check(errors.Is(err, os.ErrNotExist), Equals, true)
+	_, err = loader.Get(loader.Abs("", "broken.tpl"))
+	c.Check(err, ErrorMatches, `get .*/tpl/broken.tpl: unexpected response status '500 Internal Server Error'`)
+
+	_, err = pongo2.NewHTTPLoader("relative/path/")
+	c.Check(err, NotNil)
+}
+
+func (s *TestSuite) TestInMemoryLoader(c *C) {
+	loader := pongo2.NewInMemoryLoader(map[string]string{
+		"base.html": `<title>{% block title %}Base{% endblock %}</title>{% block content %}{% endblock %}`,
+		"page.html": `{% extends "base.html" %}{% block title %}Page{% endblock %}{% block content %}{% include "parts/item.html" with name="pongo2" %}{% endblock %}`,
+	})
+	loader.Add("parts/item.html", `<li>{{ name }}</li>`)
+
+	set := pongo2.NewSet("memory", loader)
+	tpl, err := set.FromFile("page.html")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "<title>Page</title><li>pongo2</li>")
+
+	_, err = set.FromFile("missing.html")
+	c.Check(errors.Is(err, os.ErrNotExist), Equals, true)
+
+	// Concurrent use
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("gen%d.html", i)
+			loader.Add(name, fmt.Sprintf("{{ %d * 2 }}", i))
+			if _, err := loader.Get("base.html"); err != nil {
+				c.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	tpl, err = set.FromFile("gen7.html")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "14")
+}
+
+func (s *TestSuite) TestRenderString(c *C) {
+	set := pongo2.NewSet("render string", pongo2.NewInMemoryLoader(map[string]string{
+		"parts/item.html": `<li>{{ name|shout }}</li>`,
+	}))
+	set.RegisterFilter("shout", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(strings.ToUpper(in.String()) + "!"), nil
+	})
+
+	out, err := set.RenderString("hook.html", `{{ event|shout }}: {% include "parts/item.html" %}`,
+		pongo2.Context{"event": "push", "name": "pongo2"})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "PUSH!: <li>PONGO2!</li>")
+
+	// The template isn't cached (nor available through the loader)
+	_, err = set.FromCache("hook.html")
+	c.Check(errors.Is(err, os.ErrNotExist), Equals, true)
+
+	_, err = set.RenderString("broken.html", `{{ event|unknown }}`, nil)
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Filename, Equals, "broken.html")
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseParse)
+
+	_, err = set.RenderString("failing.html", `{{ event|shout|truncate:"x" }}`, nil)
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Phase, Equals, pongo2.PhaseExecute)
+}
+
+// tagUnlessNode is a custom block tag ({% unless cond %}...{% otherwise
+// %}...{% endunless %}) implemented with Parser.ParseBodyUntil.
+type tagUnlessNode struct {
+	condition pongo2.IEvaluator
+	body      *pongo2.NodeWrapper
+	otherwise *pongo2.NodeWrapper
+}
+
+func (node *tagUnlessNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	value, err := node.condition.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	if !value.IsTrue() {
+		return node.body.Execute(ctx, writer)
+	}
+	if node.otherwise != nil {
+		return node.otherwise.Execute(ctx, writer)
+	}
+	return nil
+}
+
+func tagUnlessParser(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	condition, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	node := &tagUnlessNode{condition: condition}
+
+	body, endtag, err := doc.ParseBodyUntil("otherwise", "endunless")
+	if err != nil {
+		return nil, err
+	}
+	node.body = body
+	if endtag == "otherwise" {
+		if node.otherwise, _, err = doc.ParseBodyUntil("endunless"); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (s *TestSuite) TestParseBodyUntil(c *C) {
+	set := pongo2.NewSet("parse body until", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Assert(set.RegisterTag("unless", tagUnlessParser), IsNil)
+
+	tpl, err := set.FromString(`{% unless done %}todo{% otherwise %}done{% endunless %}|{% unless done %}only todo{% endunless %}`)
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(pongo2.Context{"done": false}), Equals, "todo|only todo")
+	c.Check(tpl.MustExecute(pongo2.Context{"done": true}), Equals, "done|")
+
+	_, err = set.FromString(`{% unless done %}todo{% otherwise done %}done{% endunless %}`)
+	c.Check(err, ErrorMatches, ".*The otherwise-tag doesn't take any arguments.*")
+
+	_, err = set.FromString(`{% unless done %}todo`)
+	c.Check(err, ErrorMatches, ".*Unexpected EOF, expected tag otherwise or endunless.*")
+}
+
+func (s *TestSuite) TestRegisterGlobal(c *C) {
+	set := pongo2.NewSet("globals", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Assert(set.RegisterGlobal("asset", func(name string) string {
+		return "/static/" + name + "?v=42"
+	}), IsNil)
+	c.Assert(set.RegisterGlobal("must_exist", func(name string) (string, error) {
+		if name != "logo.png" {
+			return "", fmt.Errorf("asset '%s' not found", name)
+		}
+		return name, nil
+	}), IsNil)
+	c.Assert(set.RegisterGlobal("site_name", "pongo2"), IsNil)
+
+	c.Check(set.RegisterGlobal("asset", "again"), ErrorMatches, "global with name 'asset' is already registered in set 'globals'")
+	c.Check(set.RegisterGlobal("not-valid", 1), ErrorMatches, "global name 'not-valid' is not a valid identifier")
+
+	tpl, err := set.FromString(`<img src="{{ asset("logo.png") }}" alt="{{ site_name }}">{{ must_exist("logo.png") }}`)
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, `<img src="/static/logo.png?v=42" alt="pongo2">logo.png`)
+
+	// The render context wins
+	c.Check(tpl.MustExecute(pongo2.Context{"site_name": "mine"}), Equals, `<img src="/static/logo.png?v=42" alt="mine">logo.png`)
+
+	tpl, err = set.FromString(`{{ must_exist("missing.png") }}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, ".*asset 'missing.png' not found.*")
+}
+
+func (s *TestSuite) TestTimezoneFilters(c *C) {
+	eventAt := time.Date(2026, 7, 1, 12, 30, 0, 0, time.UTC)
+
+	tpl, err := pongo2.FromString(`{{ event_at|localtime|date:"2006-01-02 15:04 MST" }}|{{ event_at|localtime:"user_tz"|date:"15:04 MST" }}|{{ event_at|tz:"America/New_York"|date:"15:04 MST" }}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{
+		"event_at": eventAt,
+		"timezone": "Europe/Berlin",
+		"user_tz":  time.FixedZone("UTC+5", 5*60*60),
+	})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "2026-07-01 14:30 CEST|17:30 UTC+5|08:30 EDT")
+
+	// Missing and invalid timezones fall back to UTC
+	out, err = tpl.Execute(pongo2.Context{"event_at": eventAt, "user_tz": "Mars/Olympus_Mons"})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "2026-07-01 12:30 UTC|12:30 UTC|08:30 EDT")
+
+	// ... unless strict
+	tpl, err = pongo2.FromString(`{{ event_at|localtime:"user_tz":true }}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(pongo2.Context{"event_at": eventAt, "user_tz": "Mars/Olympus_Mons"})
+	c.Check(err, ErrorMatches, ".*unknown timezone 'Mars/Olympus_Mons'.*")
+	_, err = tpl.Execute(pongo2.Context{"event_at": eventAt})
+	c.Check(err, ErrorMatches, `.*no timezone in the context \(key 'user_tz'\).*`)
+	tpl, err = pongo2.FromString(`{{ event_at|localtime:"":true }}`)
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(pongo2.Context{"event_at": eventAt})
+	c.Check(err, ErrorMatches, `.*no timezone in the context \(key 'timezone'\).*`)
+
+	// Integers (Unix timestamps) work like for the date filter
+	out, err = pongo2.RenderTemplateString(`{{ 0|tz:"Asia/Tokyo"|date:"2006-01-02 15:04" }}`, nil)
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "1970-01-01 09:00")
+}
+
+func (s *TestSuite) TestTemplateClone(c *C) {
+	tpl, err := pongo2.FromString(`{{ html }}{% autoescape on %}|{{ html }}{% endautoescape %}`)
+	c.Assert(err, IsNil)
+	ctx := pongo2.Context{"html": "<b>"}
+
+	raw := tpl.Clone()
+	raw.SetAutoescape(false)
+
+	out, err := raw.Execute(ctx)
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "<b>|&lt;b&gt;")
+
+	out, err = tpl.Execute(ctx)
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "&lt;b&gt;|&lt;b&gt;")
+
+	// The options are independent as well
+	raw.Options.StrictFilterArguments = true
+	c.Check(tpl.Options.StrictFilterArguments, Equals, false)
+
+	// Trimming the clone's output doesn't affect the original (or the
+	// next execution)
+	tpl, err = pongo2.FromString("<ul>\n  {% if true %}\n  <li>x</li>\n  {% endif %}\n</ul>")
+	c.Assert(err, IsNil)
+	trimmed := tpl.Clone()
+	trimmed.Options.TrimBlocks = true
+	trimmed.Options.LStripBlocks = true
+	for i := 0; i < 2; i++ {
+		c.Check(trimmed.MustExecute(nil), Equals, "<ul>\n  <li>x</li>\n</ul>")
+		c.Check(tpl.MustExecute(nil), Equals, "<ul>\n  \n  <li>x</li>\n  \n</ul>")
+	}
+}
+
+func (s *TestSuite) TestKeepTrailingNewline(c *C) {
+	set := pongo2.NewSet("trailing newline", pongo2.NewInMemoryLoader(map[string]string{
+		"file.txt": "{% for i in items %}{{ i }}\n{% endfor %}\n",
+		"crlf.txt": "line\r\n",
+	}))
+	ctx := pongo2.Context{"items": []int{1, 2}}
+
+	tpl, err := set.FromFile("file.txt")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(ctx), Equals, "1\n2\n\n")
+
+	set.SetKeepTrailingNewline(false)
+
+	tpl, err = set.FromFile("file.txt")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(ctx), Equals, "1\n2\n")
+
+	tpl, err = set.FromFile("crlf.txt")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "line")
+
+	// Only a single newline is removed
+	tpl, err = set.FromString("text\n\n")
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(nil), Equals, "text\n")
+}
+
+func (s *TestSuite) TestDefaultIfError(c *C) {
+	ctx := pongo2.Context{
+		"load": func() (string, error) { return "", errors.New("load failed") },
+		"ok":   func() (string, error) { return "", nil },
+		"none": nil,
+	}
+	for tpl, expected := range map[string]string{
+		`{{ load()|default_if_error:"{}" }}`:                  "{}",
+		`{{ load()|upper|default_if_error:"x"|upper }}`:       "X",
+		`{{ "4x"|int|default_if_error:0 }}`:                   "0",
+		`{{ ok()|default_if_error:"x" }}|`:                    "|",
+		`{{ none|default_if_error:"x" }}|`:                    "|",
+		`{{ 0|default_if_error:"x" }}`:                        "0",
+		`{% if load()|default_if_error:true %}yes{% endif %}`: "yes",
+	} {
+		t, err := pongo2.FromString(tpl)
+		c.Assert(err, IsNil)
+		out, err := t.Execute(ctx)
+		c.Assert(err, IsNil, Commentf("%s", tpl))
+		c.Check(out, Equals, expected, Commentf("%s", tpl))
+	}
+
+	// Without default_if_error the error is reported as usual
+	t, err := pongo2.FromString(`{{ load()|default:"x" }}`)
+	c.Assert(err, IsNil)
+	_, err = t.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*load failed")
+}
+
+// tagBoxNode wraps its body in a div; it's registered with a namespace
+// ({% liba.box %}...{% liba.endbox %}).
+type tagBoxNode struct {
+	body *pongo2.NodeWrapper
+}
+
+func (node *tagBoxNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	writer.WriteString("<div>")
+	if err := node.body.Execute(ctx, writer); err != nil {
+		return err
+	}
+	writer.WriteString("</div>")
+	return nil
+}
+
+func (s *TestSuite) TestNamespacedFiltersAndTags(c *C) {
+	set := pongo2.NewSet("namespaces", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Assert(set.RegisterFilter("liba.slugify", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(strings.Replace(strings.ToLower(in.String()), " ", "-", -1)), nil
+	}), IsNil)
+	c.Assert(set.RegisterFilter("libb.slugify", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(strings.Replace(strings.ToUpper(in.String()), " ", "_", -1) + param.String()), nil
+	}), IsNil)
+	c.Assert(set.RegisterTag("liba.box", func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+		body, _, err := doc.ParseBodyUntil("liba.endbox")
+		if err != nil {
+			return nil, err
+		}
+		return &tagBoxNode{body: body}, nil
+	}), IsNil)
+
+	tpl, err := set.FromString(`{{ title|liba.slugify }} {{ title|libb.slugify:"!"|lower }} {{ title|lower }} ` +
+		`{% liba.box %}{% filter libb.slugify %}{{ title }}{% endfilter %}{% liba.endbox %}`)
+	c.Assert(err, IsNil)
+	c.Check(tpl.MustExecute(pongo2.Context{"title": "Hello World"}), Equals,
+		"hello-world hello_world! hello world <div>HELLO_WORLD</div>")
+
+	_, err = set.FromString(`{{ title|libc.slugify }}`)
+	c.Check(err, ErrorMatches, ".*Filter 'libc.slugify' does not exist.*")
+
+	// The dots must not be surrounded by whitespace
+	_, err = set.FromString(`{{ title|liba .slugify }}`)
+	c.Check(err, NotNil)
+
+	// Global registrations
+	name := uniqueName("libg") + ".shout"
+	c.Assert(pongo2.RegisterFilter(name, func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(in.String() + "!"), nil
+	}), IsNil)
+	c.Check(parseTemplate(fmt.Sprintf(`{{ "hi"|%s }}`, name), nil), Equals, "hi!")
+}
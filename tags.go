@@ -3,7 +3,7 @@ package pongo2
 /* Incomplete:
    -----------
 
-   verbatim (only the "name" argument is missing for verbatim)
+   verbatim (only the "name" argument is missing for verbatim; "raw" is an alias handled by the lexer as well)
 
    Reconsideration:
    ----------------
@@ -21,6 +21,7 @@ package pongo2
 
 import (
 	"fmt"
+	"sync"
 )
 
 type INodeTag interface {
@@ -47,19 +48,30 @@ type tag struct {
 	parser TagParser
 }
 
-var tags map[string]*tag
+var (
+	tags map[string]*tag
+
+	// Guards tags; tags might be registered lazily, concurrently to
+	// template parsing
+	tagsMutex sync.RWMutex
+)
 
 func init() {
 	tags = make(map[string]*tag)
 }
 
-// Registers a new tag. You usually want to call this
+// Registers a new tag. If there's already a tag with the same name, an error
+// is returned and the existing tag is kept. You usually want to call this
 // function in the tag's init() function:
 // http://golang.org/doc/effective_go.html#init
 //
+// Like filters, tags can be namespaced ({% myorg.box %}...{% myorg.endbox %}).
+//
 // See http://www.florian-schlachter.de/post/pongo2/ for more about
 // writing filters and tags.
 func RegisterTag(name string, parserFn TagParser) error {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
 	_, existing := tags[name]
 	if existing {
 		return fmt.Errorf("tag with name '%s' is already registered", name)
@@ -71,9 +83,18 @@ func RegisterTag(name string, parserFn TagParser) error {
 	return nil
 }
 
+// TryRegisterTag registers a new tag like RegisterTag and returns an error
+// (keeping the existing tag) if the name is already taken. It's meant for
+// plugins which can't know which tags have been registered already.
+func TryRegisterTag(name string, parserFn TagParser) error {
+	return RegisterTag(name, parserFn)
+}
+
 // Replaces an already registered tag with a new implementation. Use this
 // function with caution since it allows you to change existing tag behaviour.
 func ReplaceTag(name string, parserFn TagParser) error {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
 	_, existing := tags[name]
 	if !existing {
 		return fmt.Errorf("tag with name '%s' does not exist (therefore cannot be overridden)", name)
@@ -88,7 +109,7 @@ func ReplaceTag(name string, parserFn TagParser) error {
 // Tag = "{%" IDENT ARGS "%}"
 func (p *Parser) parseTagElement() (INodeTag, *Error) {
 	p.Consume() // consume "{%"
-	tokenName := p.MatchType(TokenIdentifier)
+	tokenName := p.matchQualifiedName()
 
 	// Check for identifier
 	if tokenName == nil {
@@ -96,7 +117,7 @@ func (p *Parser) parseTagElement() (INodeTag, *Error) {
 	}
 
 	// Check for the existing tag
-	tag, exists := tags[tokenName.Val]
+	tag, exists := p.template.set.tag(tokenName.Val)
 	if !exists {
 		// Does not exists
 		return nil, p.Error(fmt.Sprintf("Tag '%s' not found (or beginning tag not provided)", tokenName.Val), tokenName)
@@ -0,0 +1,78 @@
+package pongo2
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The append-tag pushes an item to a list variable (starting with an empty
+// list if it's not yet defined):
+//
+//	{% for user in users %}{% append names user.Name %}{% endfor %}{{ names|join:", " }}
+//
+// Like the increment-tag, the list lives for the rest of the current
+// rendering and is visible in all scopes. An existing slice from the
+// context gets copied first, so the caller's Go data is never modified.
+type tagAppendNode struct {
+	position   *Token
+	name       string
+	expression IEvaluator
+}
+
+func (node *tagAppendNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	item, err := node.expression.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	var list []interface{}
+	current := ctx.lookupValue(node.name)
+	accumulated, isAccumulated := ctx.accumulatedValue(node.name)
+	isAccumulated = isAccumulated && current == accumulated
+
+	if own, ok := current.Interface().([]interface{}); ok && isAccumulated {
+		// A list of a previous append-tag
+		list = own
+	} else {
+		switch current.getResolvedValue().Kind() {
+		case reflect.Array, reflect.Slice:
+			for i := 0; i < current.Len(); i++ {
+				list = append(list, current.Index(i).Interface())
+			}
+		default:
+			if isAccumulated {
+				// Like the counter of an increment-tag
+				return ctx.Error(fmt.Sprintf("Can't append to '%s', it's not a list.", node.name), node.position)
+			}
+		}
+	}
+
+	ctx.setAccumulatedValue(node.name, AsValue(append(list, item.Interface())))
+	return nil
+}
+
+func tagAppendParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Expected an identifier.", nil)
+	}
+
+	expression, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed 'append'-tag arguments.", nil)
+	}
+
+	return &tagAppendNode{
+		position:   start,
+		name:       nameToken.Val,
+		expression: expression,
+	}, nil
+}
+
+func init() {
+	RegisterTag("append", tagAppendParser)
+}
@@ -0,0 +1,152 @@
+package pongo2
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// The addcss- and addjs-tags record the URL of a stylesheet or script a
+// (partial) template needs; renderassets emits the recorded assets:
+//
+//	{% addcss "widget.css" %}{% addjs "/static/widget.js" %}
+//	...
+//	{% renderassets "css" %}
+//
+// Each URL is emitted once (in the order it was added first), even if it is
+// added by several includes. The assets are collected for the whole
+// rendering, including all included templates; renderassets emits all of
+// them, wherever it's placed (like in the head of the base template). For
+// that, it writes a placeholder which is replaced at the end of the
+// rendering. Only a renderassets-tag of an included template emits the
+// assets added up to the point where it's executed.
+type tagAddAssetNode struct {
+	kind string
+	url  IEvaluator
+}
+
+type tagRenderAssetsNode struct {
+	kind string
+}
+
+// assetCollector holds the URLs recorded by addcss/addjs per kind.
+type assetCollector struct {
+	urls map[string][]string
+	seen map[string]bool
+
+	// Whether renderassets writes placeholders (see fillPlaceholders)
+	deferred bool
+}
+
+// assetPlaceholder returns the placeholder renderassets writes for the
+// assets of the given kind.
+func assetPlaceholder(kind string) string {
+	return "\x00pongo2:renderassets:" + kind + "\x00"
+}
+
+// fillPlaceholders replaces the placeholders in the rendered output with the
+// tags of the assets.
+func (collector *assetCollector) fillPlaceholders(output []byte) []byte {
+	for _, kind := range []string{"css", "js"} {
+		placeholder := []byte(assetPlaceholder(kind))
+		if bytes.Contains(output, placeholder) {
+			output = bytes.Replace(output, placeholder, []byte(collector.tags(kind)), -1)
+		}
+	}
+	return output
+}
+
+// tags returns the HTML tags of the assets of the given kind.
+func (collector *assetCollector) tags(kind string) string {
+	format := `<link rel="stylesheet" href="%s">`
+	if kind == "js" {
+		format = `<script src="%s"></script>`
+	}
+
+	tags := make([]string, 0, len(collector.urls[kind]))
+	for _, url := range collector.urls[kind] {
+		escaped, _ := filterEscape(AsValue(url), nil)
+		tags = append(tags, fmt.Sprintf(format, escaped.String()))
+	}
+	return strings.Join(tags, "\n")
+}
+
+// contextAssetsKey is the Shared-context key holding the assetCollector.
+const contextAssetsKey = "_pongo2_assets"
+
+// assets returns the asset collector of the rendering (creating it if needed).
+func (ctx *ExecutionContext) assets() *assetCollector {
+	if ctx.Shared == nil {
+		ctx.Shared = make(Context)
+	}
+	collector, ok := ctx.Shared[contextAssetsKey].(*assetCollector)
+	if !ok {
+		collector = &assetCollector{
+			urls: make(map[string][]string),
+			seen: make(map[string]bool),
+		}
+		ctx.Shared[contextAssetsKey] = collector
+	}
+	return collector
+}
+
+func (node *tagAddAssetNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	url, err := node.url.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	collector := ctx.assets()
+	key := node.kind + ":" + url.String()
+	if !collector.seen[key] {
+		collector.seen[key] = true
+		collector.urls[node.kind] = append(collector.urls[node.kind], url.String())
+	}
+	return nil
+}
+
+func (node *tagRenderAssetsNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	collector := ctx.assets()
+	if collector.deferred {
+		writer.WriteString(assetPlaceholder(node.kind))
+	} else {
+		writer.WriteString(collector.tags(node.kind))
+	}
+	return nil
+}
+
+func tagAddAssetParser(kind string) TagParser {
+	return func(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+		url, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error(fmt.Sprintf("Malformed '%s'-tag arguments.", start.Val), nil)
+		}
+
+		return &tagAddAssetNode{kind: kind, url: url}, nil
+	}
+}
+
+func tagRenderAssetsParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	kindToken := arguments.MatchType(TokenString)
+	if kindToken == nil || (kindToken.Val != "css" && kindToken.Val != "js") {
+		return nil, arguments.Error("Expected the kind of assets to render (\"css\" or \"js\").", nil)
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed 'renderassets'-tag arguments.", nil)
+	}
+
+	doc.template.rendersAssets = true
+
+	return &tagRenderAssetsNode{kind: kindToken.Val}, nil
+}
+
+func init() {
+	RegisterTag("addcss", tagAddAssetParser("css"))
+	RegisterTag("addjs", tagAddAssetParser("js"))
+	RegisterTag("renderassets", tagRenderAssetsParser)
+}
@@ -42,10 +42,10 @@ func (node *tagBlockNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 	ctx.Private["block"] = tagBlockInformation{
 		ctx:      ctx,
 		wrappers: blockWrappers[0 : lenBlockWrappers-1],
-	}
+	}.context()
 	err := blockWrapper.Execute(ctx, writer)
 	if err != nil {
-		return err
+		return err.addFrame("block '%s'", node.name)
 	}
 
 	return nil
@@ -56,26 +56,37 @@ type tagBlockInformation struct {
 	wrappers []*NodeWrapper
 }
 
-func (t tagBlockInformation) Super() string {
+// context exposes the block information to the template; the parent's
+// block content is available as {{ block.super }} (like in Django/Jinja)
+// and {{ block.Super }}.
+func (t tagBlockInformation) context() map[string]interface{} {
+	return map[string]interface{}{
+		"super": t.Super,
+		"Super": t.Super,
+	}
+}
+
+// Super renders the content of the overridden (parent) block.
+func (t tagBlockInformation) Super() (*Value, error) {
 	lenWrappers := len(t.wrappers)
 
 	if lenWrappers == 0 {
-		return ""
+		return AsSafeValue(""), nil
 	}
 
 	superCtx := NewChildExecutionContext(t.ctx)
 	superCtx.Private["block"] = tagBlockInformation{
 		ctx:      t.ctx,
 		wrappers: t.wrappers[0 : lenWrappers-1],
-	}
+	}.context()
 
 	blockWrapper := t.wrappers[lenWrappers-1]
 	buf := bytes.NewBufferString("")
 	err := blockWrapper.Execute(superCtx, &templateWriter{buf})
 	if err != nil {
-		return ""
+		return AsSafeValue(""), err
 	}
-	return buf.String()
+	return AsSafeValue(buf.String()), nil
 }
 
 func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
@@ -0,0 +1,143 @@
+package pongo2
+
+import (
+	"strings"
+)
+
+// The compress-tag minifies the rendered HTML of its body: runs of
+// whitespace are collapsed to a single space, HTML comments are removed
+// (except for conditional comments like <!--[if IE]>...<![endif]-->) and the
+// result gets trimmed. The content of pre, textarea, script and style
+// elements, of verbatim-tags and values marked with the safe filter is kept as
+// it is.
+//
+//	{% compress %}
+//	    <p>
+//	        Hello   <b>{{ user }}</b>   <!-- greeting -->
+//	    </p>
+//	{% endcompress %}
+//
+// results in <p> Hello <b>...</b> </p>. Unlike spaceless, whitespace
+// within text is collapsed as well (but never removed completely).
+type tagCompressNode struct {
+	wrapper *NodeWrapper
+}
+
+// tagCompressRawElements are the elements whose content is kept unchanged.
+var tagCompressRawElements = []string{"pre", "textarea", "script", "style"}
+
+func (node *tagCompressNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// Verbatim content and |safe values are kept as they are
+	b := &protectedBuffer{}
+
+	err := node.wrapper.Execute(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	b.writeTo(writer, compressHTML)
+
+	return nil
+}
+
+// compressHTML does the work of the compress-tag using a simple scan of the
+// HTML (no full parsing).
+func compressHTML(s string) string {
+	var b strings.Builder
+	pendingSpace := false
+
+	write := func(text string) {
+		if pendingSpace && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteString(text)
+	}
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			pendingSpace = true
+			i++
+		case strings.HasPrefix(s[i:], "<!--"):
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				// Unclosed comment; keep the rest as it is
+				write(s[i:])
+				return b.String()
+			}
+			end += i + 4 + len("-->")
+			if strings.HasPrefix(s[i+4:], "[if") || strings.HasPrefix(s[i+4:], "<![endif]") {
+				write(s[i:end])
+			}
+			i = end
+		case c == '<':
+			end := compressRawElementEnd(s, i)
+			if end < 0 {
+				write(s[i : i+1])
+				i++
+				continue
+			}
+			write(s[i:end])
+			i = end
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r\f\v<", rune(s[i])) {
+				i++
+			}
+			write(s[start:i])
+		}
+	}
+
+	return b.String()
+}
+
+// compressRawElementEnd returns the end (after the closing tag) of the raw
+// element (see tagCompressRawElements) starting at s[start], or -1 if there's
+// none. An element which isn't closed lasts until the end of s.
+func compressRawElementEnd(s string, start int) int {
+	for _, name := range tagCompressRawElements {
+		nameEnd := start + 1 + len(name)
+		if nameEnd > len(s) || !strings.EqualFold(s[start+1:nameEnd], name) {
+			continue
+		}
+		if nameEnd < len(s) && !strings.ContainsRune(" \t\n\r\f\v/>", rune(s[nameEnd])) {
+			continue // like <prefix>
+		}
+
+		for closing := nameEnd; ; closing++ {
+			next := strings.Index(s[closing:], "</")
+			if next < 0 {
+				return len(s)
+			}
+			closing += next
+			if closing+2+len(name) <= len(s) && strings.EqualFold(s[closing+2:closing+2+len(name)], name) {
+				if gt := strings.IndexByte(s[closing:], '>'); gt >= 0 {
+					return closing + gt + 1
+				}
+				return len(s)
+			}
+		}
+	}
+	return -1
+}
+
+func tagCompressParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	compressNode := &tagCompressNode{}
+
+	wrapper, _, err := doc.WrapUntilTag("endcompress")
+	if err != nil {
+		return nil, err
+	}
+	compressNode.wrapper = wrapper
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed compress-tag arguments.", nil)
+	}
+
+	return compressNode, nil
+}
+
+func init() {
+	RegisterTag("compress", tagCompressParser)
+}
@@ -1,5 +1,14 @@
 package pongo2
 
+// tagCycleLastKey is the Shared-context key under which the most recently
+// executed cycle is stored (used by resetcycle without a name).
+const tagCycleLastKey = "_pongo2_last_cycle"
+
+// tagCycleNamedKey is the Shared-context key of the named cycles (map of
+// name to *tagCycleNode); resetcycle finds them there even if the name was
+// bound in a nested scope (like an inner loop).
+const tagCycleNamedKey = "_pongo2_named_cycles"
+
 type tagCycleValue struct {
 	node  *tagCycleNode
 	value *Value
@@ -33,6 +42,7 @@ func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		// Update the cycle value with next value
 		item := t.node.args[t.node.idx%len(t.node.args)]
 		t.node.idx++
+		t.node.setLast(ctx)
 
 		val, err := item.Evaluate(ctx)
 		if err != nil {
@@ -46,6 +56,7 @@ func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		}
 	} else {
 		// Regular call
+		node.setLast(ctx)
 
 		cycleValue := &tagCycleValue{
 			node:  node,
@@ -54,6 +65,7 @@ func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 
 		if node.asName != "" {
 			ctx.Private[node.asName] = cycleValue
+			node.setNamed(ctx)
 		}
 		if !node.silent {
 			writer.WriteString(val.String())
@@ -63,6 +75,24 @@ func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 	return nil
 }
 
+func (node *tagCycleNode) setLast(ctx *ExecutionContext) {
+	if ctx.Shared != nil {
+		ctx.Shared[tagCycleLastKey] = node
+	}
+}
+
+func (node *tagCycleNode) setNamed(ctx *ExecutionContext) {
+	if ctx.Shared == nil {
+		return
+	}
+	named, ok := ctx.Shared[tagCycleNamedKey].(map[string]*tagCycleNode)
+	if !ok {
+		named = make(map[string]*tagCycleNode)
+		ctx.Shared[tagCycleNamedKey] = named
+	}
+	named[node.asName] = node
+}
+
 // HINT: We're not supporting the old comma-separated list of expressions argument-style
 func tagCycleParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	cycleNode := &tagCycleNode{
@@ -0,0 +1,62 @@
+package pongo2
+
+// The defaultblock-tag renders the value of an expression if it's true (not
+// empty), otherwise its body (fallback markup); like the default filter, but
+// with rich fallback content:
+//
+//	{% defaultblock sidebar %}<p>Nothing to see here.</p>{% enddefaultblock %}
+//
+// The value is escaped like a variable ({{ sidebar }}) would be.
+type tagDefaultblockNode struct {
+	position *Token
+	expr     IEvaluator
+	wrapper  *NodeWrapper
+}
+
+func (node *tagDefaultblockNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	val, err := node.expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !val.IsTrue() {
+		return node.wrapper.Execute(ctx, writer)
+	}
+
+	if ctx.Autoescape && !node.expr.FilterApplied("safe") && !val.safe && ctx.escapeModeEscapes(val) {
+		val, err = ApplyFilter("escape", val, nil)
+		if err != nil {
+			return err
+		}
+	}
+	writer.WriteString(val.String())
+	return nil
+}
+
+func tagDefaultblockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	defaultblockNode := &tagDefaultblockNode{
+		position: start,
+	}
+
+	expr, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	defaultblockNode.expr = expr
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed defaultblock-tag arguments.", nil)
+	}
+
+	wrapper, _, err := doc.WrapUntilTag("enddefaultblock")
+	if err != nil {
+		return nil, err
+	}
+	defaultblockNode.wrapper = wrapper
+
+	return defaultblockNode, nil
+}
+
+func init() {
+	RegisterTag("defaultblock", tagDefaultblockParser)
+}
@@ -5,8 +5,9 @@ import (
 )
 
 type nodeFilterCall struct {
-	name      string
-	paramExpr IEvaluator
+	name           string
+	paramExpr      IEvaluator
+	moreParamExprs []IEvaluator
 }
 
 type tagFilterNode struct {
@@ -26,18 +27,19 @@ func (node *tagFilterNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 	value := AsValue(temp.String())
 
 	for _, call := range node.filterChain {
-		var param *Value
-		if call.paramExpr != nil {
-			param, err = call.paramExpr.Evaluate(ctx)
-			if err != nil {
-				return err
-			}
+		param, err := evaluateFilterParameters(ctx, call.paramExpr, call.moreParamExprs)
+		if err != nil {
+			return err
+		}
+		if fn, exists := ctx.template.set.filter(call.name); exists {
+			value, err = applyFilter(call.name, fn, exists, value, param)
+		} else if fn, exists := lookupFilterWithContext(call.name); exists {
+			value, err = fn(ctx, value, param)
 		} else {
-			param = AsValue(nil)
+			value, err = applyFilter(call.name, nil, false, value, param)
 		}
-		value, err = ApplyFilter(call.name, value, param)
 		if err != nil {
-			return ctx.Error(err.Error(), node.position)
+			return ctx.OrigError(err, node.position)
 		}
 	}
 
@@ -60,11 +62,14 @@ func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 	for arguments.Remaining() > 0 {
 		filterCall := &nodeFilterCall{}
 
-		nameToken := arguments.MatchType(TokenIdentifier)
+		nameToken := arguments.matchQualifiedName()
 		if nameToken == nil {
 			return nil, arguments.Error("Expected a filter name (identifier).", nil)
 		}
 		filterCall.name = nameToken.Val
+		if err := arguments.checkDebugFilter(nameToken); err != nil {
+			return nil, err
+		}
 
 		if arguments.MatchOne(TokenSymbol, ":") != nil {
 			// Filter parameter
@@ -74,6 +79,14 @@ func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 				return nil, err
 			}
 			filterCall.paramExpr = expr
+
+			filterCall.moreParamExprs, err = arguments.parseMoreFilterArguments(nameToken)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := arguments.checkFilterArgument(nameToken, filterCall.paramExpr != nil); err != nil {
+			return nil, err
 		}
 
 		filterNode.filterChain = append(filterNode.filterChain, filterCall)
@@ -2,7 +2,7 @@ package pongo2
 
 type tagForNode struct {
 	key             string
-	value           string // only for maps: for key, value in map
+	value           string // for key, value in map; for index, item in list
 	objectEvaluator IEvaluator
 	reversed        bool
 	sorted          bool
@@ -46,11 +46,21 @@ func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (f
 
 	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
 		// There's something to iterate over (correct type and at least 1 item)
+		if err := forCtx.checkDeadline(); err != nil {
+			forError = err
+			return false
+		}
 
 		// Update loop infos and public context
-		forCtx.Private[node.key] = key
 		if value != nil {
+			forCtx.Private[node.key] = key
 			forCtx.Private[node.value] = value
+		} else if node.value != "" {
+			// Two-variable form on a list/string binds the index and the item
+			forCtx.Private[node.key] = AsValue(idx)
+			forCtx.Private[node.value] = key
+		} else {
+			forCtx.Private[node.key] = key
 		}
 		loopInfo.Counter = idx + 1
 		loopInfo.Counter0 = idx
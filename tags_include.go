@@ -1,5 +1,9 @@
 package pongo2
 
+import (
+	"fmt"
+)
+
 type tagIncludeNode struct {
 	tpl               *Template
 	filenameEvaluator IEvaluator
@@ -8,6 +12,7 @@ type tagIncludeNode struct {
 	filename          string
 	withPairs         map[string]IEvaluator
 	ifExists          bool
+	set               *TemplateSet // only set when including from another set
 }
 
 func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
@@ -42,9 +47,18 @@ func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter
 		}
 
 		// Get include-filename
-		includedFilename := ctx.template.set.resolveFilename(ctx.template, filename.String())
+		set, base := ctx.template.set, ctx.template
+		if node.set != nil {
+			set, base = node.set, nil
+		}
+		includedFilename := set.resolveFilename(base, filename.String())
+		// The restrictions of the executing template's set apply to the
+		// includes from other sets as well
+		if !ctx.template.set.isIncludable(includedFilename) || !set.isIncludable(includedFilename) {
+			return ctx.Error(fmt.Sprintf("Template '%s' is not includable (not in the set's list of includable templates).", filename.String()), nil)
+		}
 
-		includedTpl, err2 := ctx.template.set.FromFile(includedFilename)
+		includedTpl, err2 := set.fromFile(includedFilename, ctx.template, true)
 		if err2 != nil {
 			// if this is ReadFile error, and "if_exists" flag is enabled
 			if node.ifExists && err2.(*Error).Sender == "fromfile" {
@@ -52,16 +66,16 @@ func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter
 			}
 			return err2.(*Error)
 		}
-		err2 = includedTpl.ExecuteWriter(includeCtx, writer)
+		err2 = includedTpl.executeIncluded(ctx, includeCtx, writer)
 		if err2 != nil {
-			return err2.(*Error)
+			return err2.(*Error).addFrame("include '%s'", includedTpl.name)
 		}
 		return nil
 	}
 	// Template is already parsed with static filename
-	err := node.tpl.ExecuteWriter(includeCtx, writer)
+	err := node.tpl.executeIncluded(ctx, includeCtx, writer)
 	if err != nil {
-		return err.(*Error)
+		return err.(*Error).addFrame("include '%s'", node.tpl.name)
 	}
 	return nil
 }
@@ -72,6 +86,27 @@ func (node *tagIncludeEmptyNode) Execute(ctx *ExecutionContext, writer TemplateW
 	return nil
 }
 
+// parseFromSet parses the optional `from "setname"` argument which includes
+// the template from a set registered with TemplateSet.RegisterIncludeSet().
+func (node *tagIncludeNode) parseFromSet(doc *Parser, arguments *Parser) *Error {
+	if arguments.Match(TokenIdentifier, "from") == nil {
+		return nil
+	}
+
+	setToken := arguments.MatchType(TokenString)
+	if setToken == nil {
+		return arguments.Error("Expected the name of a template set (string) after 'from'.", nil)
+	}
+
+	set, has := doc.template.set.includeSets[setToken.Val]
+	if !has {
+		return arguments.Error(fmt.Sprintf("Template set '%s' is not registered for includes.", setToken.Val), setToken)
+	}
+	node.set = set
+
+	return nil
+}
+
 func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	includeNode := &tagIncludeNode{
 		withPairs: make(map[string]IEvaluator),
@@ -80,15 +115,24 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 	if filenameToken := arguments.MatchType(TokenString); filenameToken != nil {
 		// prepared, static template
 
+		// "from" another (registered) template set
+		if err := includeNode.parseFromSet(doc, arguments); err != nil {
+			return nil, err
+		}
+
 		// "if_exists" flag
 		ifExists := arguments.Match(TokenIdentifier, "if_exists") != nil
 
 		// Get include-filename
-		includedFilename := doc.template.set.resolveFilename(doc.template, filenameToken.Val)
+		set, base := doc.template.set, doc.template
+		if includeNode.set != nil {
+			set, base = includeNode.set, nil
+		}
+		includedFilename := set.resolveFilename(base, filenameToken.Val)
 
 		// Parse the parent
 		includeNode.filename = includedFilename
-		includedTpl, err := doc.template.set.FromFile(includedFilename)
+		includedTpl, err := set.fromFile(includedFilename, doc.template, false)
 		if err != nil {
 			// if this is ReadFile error, and "if_exists" token presents we should create and empty node
 			if err.(*Error).Sender == "fromfile" && ifExists {
@@ -105,6 +149,9 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 		}
 		includeNode.filenameEvaluator = filenameEvaluator
 		includeNode.lazy = true
+		if err := includeNode.parseFromSet(doc, arguments); err != nil {
+			return nil, err
+		}
 		includeNode.ifExists = arguments.Match(TokenIdentifier, "if_exists") != nil // "if_exists" flag
 	}
 
@@ -0,0 +1,39 @@
+package pongo2
+
+// The increment-tag adds 1 to an integer variable (starting from 0 if it's
+// not yet defined or not an integer):
+//
+//	{% for item in items %}{% increment counter %}{% endfor %}{{ counter }}
+//
+// The counter lives for the rest of the current rendering and is visible in
+// all scopes (loops, blocks, ...). It never modifies the Go data passed in
+// through the context; a counter initialized from a context variable is a copy.
+type tagIncrementNode struct {
+	name string
+}
+
+func (node *tagIncrementNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	count := 0
+	if current := ctx.lookupValue(node.name); current.IsInteger() {
+		count = current.Integer()
+	}
+	ctx.setAccumulatedValue(node.name, AsValue(count+1))
+	return nil
+}
+
+func tagIncrementParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Expected an identifier.", nil)
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed 'increment'-tag arguments.", nil)
+	}
+
+	return &tagIncrementNode{name: nameToken.Val}, nil
+}
+
+func init() {
+	RegisterTag("increment", tagIncrementParser)
+}
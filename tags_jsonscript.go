@@ -0,0 +1,69 @@
+package pongo2
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+)
+
+type tagJSONScriptNode struct {
+	position *Token
+	value    IEvaluator
+	id       IEvaluator
+}
+
+func (node *tagJSONScriptNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := node.value.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := node.id.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// json.Marshal escapes <, >, & as well as U+2028 and U+2029, so the
+	// encoded data can't terminate the surrounding script element.
+	data, jsonErr := json.Marshal(value.Interface())
+	if jsonErr != nil {
+		return ctx.OrigError(jsonErr, node.position)
+	}
+
+	writer.WriteString(fmt.Sprintf(`<script id="%s" type="application/json">%s</script>`,
+		html.EscapeString(id.String()), data))
+
+	return nil
+}
+
+func tagJSONScriptParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	jsonScriptNode := &tagJSONScriptNode{
+		position: start,
+	}
+
+	value, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	jsonScriptNode.value = value
+
+	if arguments.Remaining() == 0 {
+		return nil, arguments.Error("json_script-tag requires an element id as second argument.", nil)
+	}
+
+	id, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	jsonScriptNode.id = id
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed json_script-tag arguments.", nil)
+	}
+
+	return jsonScriptNode, nil
+}
+
+func init() {
+	RegisterTag("json_script", tagJSONScriptParser)
+}
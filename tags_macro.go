@@ -35,7 +35,7 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 			valueExpr, err := v.Evaluate(ctx)
 			if err != nil {
 				ctx.Logf(err.Error())
-				return AsSafeValue(""), err
+				return AsSafeValue(""), err.addFrame("macro '%s'", node.name)
 			}
 
 			argsCtx[k] = valueExpr
@@ -63,7 +63,7 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 	var b bytes.Buffer
 	err := node.wrapper.Execute(macroCtx, &b)
 	if err != nil {
-		return AsSafeValue(""), err.updateFromTokenIfNeeded(ctx.template, node.position)
+		return AsSafeValue(""), err.updateFromTokenIfNeeded(ctx.template, node.position).addFrame("macro '%s'", node.name)
 	}
 
 	return AsSafeValue(b.String()), nil
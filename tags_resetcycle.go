@@ -0,0 +1,56 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+type tagResetCycleNode struct {
+	position *Token
+	name     string
+}
+
+func (node *tagResetCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	var cycle *tagCycleNode
+
+	if node.name != "" {
+		if cv, ok := ctx.Private[node.name].(*tagCycleValue); ok {
+			cycle = cv.node
+		} else {
+			// Bound in a nested scope, like an inner loop
+			named, _ := ctx.Shared[tagCycleNamedKey].(map[string]*tagCycleNode)
+			cycle = named[node.name]
+		}
+		if cycle == nil {
+			return ctx.Error(fmt.Sprintf("Named cycle '%s' does not exist.", node.name), node.position)
+		}
+	} else {
+		// Without a name the most recently used cycle is reset
+		cycle, _ = ctx.Shared[tagCycleLastKey].(*tagCycleNode)
+		if cycle == nil {
+			return nil
+		}
+	}
+
+	cycle.idx = 0
+	return nil
+}
+
+func tagResetCycleParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	resetNode := &tagResetCycleNode{
+		position: start,
+	}
+
+	if nameToken := arguments.MatchType(TokenIdentifier); nameToken != nil {
+		resetNode.name = nameToken.Val
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed resetcycle-tag.", nil)
+	}
+
+	return resetNode, nil
+}
+
+func init() {
+	RegisterTag("resetcycle", tagResetCycleParser)
+}
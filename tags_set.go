@@ -1,11 +1,27 @@
 package pongo2
 
+import (
+	"bytes"
+)
+
 type tagSetNode struct {
 	name       string
 	expression IEvaluator
+	wrapper    *NodeWrapper // block form: {% set name %}...{% endset %}
 }
 
 func (node *tagSetNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if node.wrapper != nil {
+		// Capture the rendered body; it's rendered once with the current
+		// context and can be emitted as often as needed afterwards
+		temp := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB size
+		if err := node.wrapper.Execute(ctx, temp); err != nil {
+			return err
+		}
+		ctx.Private[node.name] = AsSafeValue(temp.String())
+		return nil
+	}
+
 	// Evaluate expression
 	value, err := node.expression.Evaluate(ctx)
 	if err != nil {
@@ -26,6 +42,19 @@ func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 	}
 	node.name = typeToken.Val
 
+	if arguments.Remaining() == 0 {
+		// Block form, capturing the body's output
+		wrapper, endargs, err := doc.WrapUntilTag("endset")
+		if err != nil {
+			return nil, err
+		}
+		if endargs.Count() > 0 {
+			return nil, endargs.Error("Arguments not allowed here.", nil)
+		}
+		node.wrapper = wrapper
+		return node, nil
+	}
+
 	if arguments.Match(TokenSymbol, "=") == nil {
 		return nil, arguments.Error("Expected '='.", nil)
 	}
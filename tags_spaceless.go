@@ -1,7 +1,6 @@
 package pongo2
 
 import (
-	"bytes"
 	"regexp"
 )
 
@@ -12,23 +11,24 @@ type tagSpacelessNode struct {
 var tagSpacelessRegexp = regexp.MustCompile(`(?U:(<.*>))([\t\n\v\f\r ]+)(?U:(<.*>))`)
 
 func (node *tagSpacelessNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	b := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB
+	// Verbatim content and |safe values are kept as they are
+	b := &protectedBuffer{}
 
 	err := node.wrapper.Execute(ctx, b)
 	if err != nil {
 		return err
 	}
 
-	s := b.String()
-	// Repeat this recursively
-	changed := true
-	for changed {
-		s2 := tagSpacelessRegexp.ReplaceAllString(s, "$1$3")
-		changed = s != s2
-		s = s2
-	}
-
-	writer.WriteString(s)
+	b.writeTo(writer, func(s string) string {
+		// Repeat this recursively
+		changed := true
+		for changed {
+			s2 := tagSpacelessRegexp.ReplaceAllString(s, "$1$3")
+			changed = s != s2
+			s = s2
+		}
+		return s
+	})
 
 	return nil
 }
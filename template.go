@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -24,6 +26,81 @@ func (tw *templateWriter) Write(b []byte) (int, error) {
 	return tw.w.Write(b)
 }
 
+// protectedWriter is implemented by the buffers of the whitespace tags
+// (spaceless and compress): text written using WriteProtected, which is the
+// content of verbatim-tags and values marked with the safe filter, isn't
+// modified by them. See writeProtected.
+type protectedWriter interface {
+	TemplateWriter
+	WriteProtected(s string)
+}
+
+// writeProtected writes s as protected text if the writer supports it
+// (otherwise it's written as usual).
+func writeProtected(writer TemplateWriter, s string) {
+	if pw, ok := writer.(protectedWriter); ok {
+		pw.WriteProtected(s)
+		return
+	}
+	writer.WriteString(s)
+}
+
+// protectedBuffer is the protectedWriter of the whitespace tags. The
+// protected parts are kept separately; the text contains a placeholder
+// ("\x00" index "\x00") for each of them instead. If a part starts (ends)
+// with an HTML tag, the placeholder starts (ends) with the pseudo tag
+// "<\x00>", so the whitespace tags treat the whitespace next to it like the
+// whitespace next to a tag.
+type protectedBuffer struct {
+	text      strings.Builder
+	protected []string
+}
+
+var protectedPlaceholderRegexp = regexp.MustCompile("(?:<\x00>)?\x00([0-9]+)\x00(?:<\x00>)?")
+
+func (b *protectedBuffer) Write(p []byte) (int, error) {
+	return b.WriteString(string(p))
+}
+
+func (b *protectedBuffer) WriteString(s string) (int, error) {
+	// NUL characters would be taken for placeholders; browsers replace them
+	// with U+FFFD (as invalid in HTML) anyway
+	b.text.WriteString(strings.Replace(s, "\x00", "\uFFFD", -1))
+	return len(s), nil
+}
+
+func (b *protectedBuffer) WriteProtected(s string) {
+	if strings.HasPrefix(s, "<") {
+		b.text.WriteString("<\x00>")
+	}
+	b.text.WriteString("\x00" + strconv.Itoa(len(b.protected)) + "\x00")
+	if strings.HasSuffix(s, ">") {
+		b.text.WriteString("<\x00>")
+	}
+	b.protected = append(b.protected, s)
+}
+
+// writeTo writes the buffered text modified by fn (which gets the text
+// including the placeholders; nil keeps the text as it is) to the writer.
+// The protected parts stay protected if the writer is a protectedWriter as
+// well (for nested whitespace tags).
+func (b *protectedBuffer) writeTo(writer TemplateWriter, fn func(string) string) {
+	s := b.text.String()
+	if fn != nil {
+		s = fn(s)
+	}
+
+	last := 0
+	for _, m := range protectedPlaceholderRegexp.FindAllStringSubmatchIndex(s, -1) {
+		writer.WriteString(s[last:m[0]])
+		if i, err := strconv.Atoi(s[m[2]:m[3]]); err == nil && i < len(b.protected) {
+			writeProtected(writer, b.protected[i])
+		}
+		last = m[1]
+	}
+	writer.WriteString(s[last:])
+}
+
 type Template struct {
 	set *TemplateSet
 
@@ -37,6 +114,12 @@ type Template struct {
 	tokens []*Token
 	parser *Parser
 
+	// Template which included/extended/imported this one (nil = root) and the
+	// nesting depth (0 = root); used to enforce limits and detect cycles
+	includer     *Template
+	depth        int
+	resolvedName string
+
 	// first come, first serve (it's important to not override existing entries in here)
 	level          int
 	parent         *Template
@@ -47,16 +130,47 @@ type Template struct {
 	// Output
 	root *nodeDocument
 
+	// Templates consisting of text only are written without being executed
+	isStatic   bool
+	staticText string
+
+	// Whether the template contains a renderassets-tag; its output is then
+	// buffered to fill in the assets at the end (see executeNested)
+	rendersAssets bool
+
+	// Parse errors collected while recovering (see ParseCollectErrors)
+	collectErrors bool
+	parseErrors   []*Error
+
+	// Autoescape mode for this template (nil = the default, see SetAutoescape)
+	autoescape *bool
+
 	// Options allow you to change the behavior of template-engine.
 	// You can change the options before calling the Execute method.
 	Options *Options
 }
 
 func newTemplateString(set *TemplateSet, tpl []byte) (*Template, error) {
-	return newTemplate(set, "<string>", true, tpl)
+	return newTemplate(set, "<string>", true, tpl, nil, "")
 }
 
-func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*Template, error) {
+func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte, includer *Template, resolvedName string) (*Template, error) {
+	t, err := newLexedTemplate(set, name, isTplString, tpl, includer, resolvedName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	err = t.parse()
+	if err != nil {
+		return nil, err.withPhase(PhaseParse)
+	}
+
+	return t, nil
+}
+
+// newLexedTemplate creates the template and tokenizes it (without parsing).
+func newLexedTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte, includer *Template, resolvedName string) (*Template, *Error) {
 	strTpl := string(tpl)
 
 	// Create the template
@@ -64,65 +178,74 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 		set:            set,
 		isTplString:    isTplString,
 		name:           name,
+		includer:       includer,
+		resolvedName:   resolvedName,
 		tpl:            strTpl,
 		size:           len(strTpl),
 		blocks:         make(map[string]*NodeWrapper),
 		exportedMacros: make(map[string]*tagMacroNode),
 		Options:        newOptions(),
 	}
+	if includer != nil {
+		t.depth = includer.depth + 1
+	}
 	// Copy all settings from another Options.
 	t.Options.Update(set.Options)
 
 	// Tokenize it
 	tokens, err := lex(name, strTpl)
 	if err != nil {
-		return nil, err
+		return nil, err.withPhase(PhaseLex)
 	}
 	t.tokens = tokens
 
+	if set.stripTrailingNewline {
+		stripTrailingNewline(tokens)
+	}
+
 	// For debugging purposes, show all tokens:
 	/*for i, t := range tokens {
 		fmt.Printf("%3d. %s\n", i, t)
 	}*/
 
-	// Parse it
-	err = t.parse()
-	if err != nil {
-		return nil, err
-	}
-
 	return t, nil
 }
 
-func (tpl *Template) newContextForExecution(context Context) (*Template, *ExecutionContext, error) {
-	if tpl.Options.TrimBlocks || tpl.Options.LStripBlocks {
-		// Issue #94 https://github.com/flosch/pongo2/issues/94
-		// If an application configures pongo2 template to trim_blocks,
-		// the first newline after a template tag is removed automatically (like in PHP).
-		prev := &Token{
-			Typ: TokenHTML,
-			Val: "\n",
-		}
-
-		for _, t := range tpl.tokens {
-			if tpl.Options.LStripBlocks {
-				if prev.Typ == TokenHTML && t.Typ != TokenHTML && t.Val == "{%" {
-					prev.Val = strings.TrimRight(prev.Val, "\t ")
-				}
-			}
+// stripTrailingNewline removes a single newline (\n or \r\n) from the end
+// of the template's text (see TemplateSet.SetKeepTrailingNewline).
+func stripTrailingNewline(tokens []*Token) {
+	if len(tokens) == 0 {
+		return
+	}
+	last := tokens[len(tokens)-1]
+	if last.Typ != TokenHTML || !strings.HasSuffix(last.Val, "\n") {
+		return
+	}
+	last.Val = strings.TrimSuffix(strings.TrimSuffix(last.Val, "\n"), "\r")
+}
 
-			if tpl.Options.TrimBlocks {
-				if prev.Typ != TokenHTML && t.Typ == TokenHTML && prev.Val == "%}" {
-					if len(t.Val) > 0 && t.Val[0] == '\n' {
-						t.Val = t.Val[1:len(t.Val)]
-					}
-				}
-			}
+// Clone returns a shallow copy of the template which shares the parsed node
+// tree with the original, but has its own options and autoescape mode. It's
+// a cheap way to render the same template with different settings:
+//
+//	raw := tpl.Clone()
+//	raw.SetAutoescape(false)
+//
+// The node tree must be treated as read-only.
+func (tpl *Template) Clone() *Template {
+	clone := *tpl
+	clone.Options = newOptions().Update(tpl.Options)
+	return &clone
+}
 
-			prev = t
-		}
-	}
+// SetAutoescape enables or disables the autoescaping of this template,
+// overriding the package-wide default (see the global SetAutoescape). The
+// autoescape-tag still applies within the template.
+func (tpl *Template) SetAutoescape(enabled bool) {
+	tpl.autoescape = &enabled
+}
 
+func (tpl *Template) newContextForExecution(context Context) (*Template, *ExecutionContext, error) {
 	// Determine the parent to be executed (for template inheritance)
 	parent := tpl
 	for parent.parent != nil {
@@ -159,24 +282,103 @@ func (tpl *Template) newContextForExecution(context Context) (*Template, *Execut
 
 	// Create operational context
 	ctx := newExecutionContext(parent, newContext)
+	ctx.options = tpl.Options
+	if tpl.autoescape != nil {
+		ctx.Autoescape = *tpl.autoescape
+	}
 
 	return parent, ctx, nil
 }
 
 func (tpl *Template) execute(context Context, writer TemplateWriter) error {
+	return tpl.executeNested(context, writer, nil)
+}
+
+// executeNested executes the template; if it's included (or rendered) by
+// another template, the including template's execution context is given:
+// its deadline keeps applying and the assets are collected for both.
+// Otherwise a new deadline is started (if the set has a render timeout).
+func (tpl *Template) executeNested(context Context, writer TemplateWriter, includerCtx *ExecutionContext) error {
+	if tpl.isStatic {
+		// The context is validated like for every other template
+		if context != nil {
+			if err := tpl.set.Globals.checkForValidIdentifiers(); err != nil {
+				return err.withPhase(PhaseExecute)
+			}
+			if err := context.checkForValidIdentifiers(); err != nil {
+				return err.withPhase(PhaseExecute)
+			}
+		}
+		writer.WriteString(tpl.staticText)
+		return nil
+	}
+
 	parent, ctx, err := tpl.newContextForExecution(context)
 	if err != nil {
+		return errorWithPhase(err, PhaseExecute)
+	}
+
+	if includerCtx != nil {
+		ctx.deadline = includerCtx.deadline
+		ctx.Shared[contextAssetsKey] = includerCtx.assets()
+	} else if tpl.set.renderTimeout > 0 {
+		ctx.deadline = newRenderDeadline(tpl.set.renderTimeout)
+		defer ctx.deadline.stop()
+	}
+
+	if includerCtx == nil && tpl.rendersAssetsInChain() {
+		// renderassets emits the assets added by the whole rendering, even
+		// the ones added after it (like in the head of the page)
+		collector := ctx.assets()
+		collector.deferred = true
+		buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
+		if err := parent.root.Execute(ctx, buffer); err != nil {
+			return err.withPhase(PhaseExecute)
+		}
+		_, err := writer.Write(collector.fillPlaceholders(buffer.Bytes()))
 		return err
 	}
 
 	// Run the selected document
 	if err := parent.root.Execute(ctx, writer); err != nil {
-		return err
+		return err.withPhase(PhaseExecute)
 	}
 
 	return nil
 }
 
+// rendersAssetsInChain returns whether the template or one of the templates
+// it extends contains a renderassets-tag.
+func (tpl *Template) rendersAssetsInChain() bool {
+	for t := tpl; t != nil; t = t.parent {
+		if t.rendersAssets {
+			return true
+		}
+	}
+	return false
+}
+
+// executeIncluded executes the template for the include-tag (buffered, like
+// ExecuteWriter); see executeNested.
+func (tpl *Template) executeIncluded(parentCtx *ExecutionContext, context Context, writer TemplateWriter) error {
+	if _, ok := writer.(protectedWriter); ok {
+		// Within a whitespace tag; the protected parts must stay protected
+		buffer := &protectedBuffer{}
+		if err := tpl.executeNested(context, buffer, parentCtx); err != nil {
+			return err
+		}
+		buffer.writeTo(writer, nil)
+		return nil
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
+	if err := tpl.executeNested(context, buffer, parentCtx); err != nil {
+		return err
+	}
+	_, err := buffer.WriteTo(writer)
+	return err
+}
+
 func (tpl *Template) newTemplateWriterAndExecute(context Context, writer io.Writer) error {
 	return tpl.execute(context, &templateWriter{w: writer})
 }
@@ -206,6 +408,17 @@ func (tpl *Template) ExecuteWriter(context Context, writer io.Writer) error {
 	return nil
 }
 
+// ExecuteWithGlobals behaves like ExecuteWriter, but additionally provides
+// per-call globals (like a CSRF token or a request ID) without the need to
+// copy them into the context. The context takes precedence over the globals,
+// which in turn take precedence over the set's Globals.
+func (tpl *Template) ExecuteWithGlobals(context Context, globals Context, writer io.Writer) error {
+	merged := make(Context, len(globals)+len(context))
+	merged.Update(globals)
+	merged.Update(context)
+	return tpl.ExecuteWriter(merged, writer)
+}
+
 // Same as ExecuteWriter. The only difference between both functions is that
 // this function might already have written parts of the generated template in the
 // case of an execution error because there's no intermediate buffer involved for
@@ -237,6 +450,25 @@ func (tpl *Template) Execute(context Context) (string, error) {
 
 }
 
+// MustExecute behaves like Execute, but panics (with the *Error) if the
+// execution fails. It's meant for tests and simple scripts, not for the use
+// in production code.
+func (tpl *Template) MustExecute(context Context) string {
+	out, err := tpl.Execute(context)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// MustExecuteWriter behaves like ExecuteWriter, but panics (with the *Error)
+// if the execution fails. Like MustExecute it's not meant for production use.
+func (tpl *Template) MustExecuteWriter(context Context, writer io.Writer) {
+	if err := tpl.ExecuteWriter(context, writer); err != nil {
+		panic(err)
+	}
+}
+
 func (tpl *Template) ExecuteBlocks(context Context, blocks []string) (map[string]string, error) {
 	var parents []*Template
 	result := make(map[string]string)
@@ -251,7 +483,7 @@ func (tpl *Template) ExecuteBlocks(context Context, blocks []string) (map[string
 		buffer := bytes.NewBuffer(make([]byte, 0, int(float64(t.size)*1.3)))
 		_, ctx, err := t.newContextForExecution(context)
 		if err != nil {
-			return nil, err
+			return nil, errorWithPhase(err, PhaseExecute)
 		}
 		for _, blockName := range blocks {
 			if _, ok := result[blockName]; ok {
@@ -260,7 +492,7 @@ func (tpl *Template) ExecuteBlocks(context Context, blocks []string) (map[string
 			if blockWrapper, ok := t.blocks[blockName]; ok {
 				bErr := blockWrapper.Execute(ctx, buffer)
 				if bErr != nil {
-					return nil, bErr
+					return nil, bErr.withPhase(PhaseExecute)
 				}
 				result[blockName] = buffer.String()
 				buffer.Reset()
@@ -0,0 +1,239 @@
+package pongo2
+
+import (
+	"sort"
+)
+
+// Fields returns the (sorted) names of all top-level context variables the
+// template reads, for example "user" for {{ user.name }}. Variables
+// introduced by the template itself (like loop variables or names defined
+// by the with-, set- or macro-tag) are not reported, neither is the
+// "pongo2" meta key. Parent templates (extends) and statically included
+// templates are taken into account as well.
+//
+// Only the built-in tags are analyzed; variables used within the arguments
+// of custom tags are not reported.
+func (tpl *Template) Fields() []string {
+	c := &fieldCollector{
+		fields:   make(map[string]bool),
+		visiting: make(map[*Template]bool),
+	}
+	c.walkTemplate(tpl, make(map[string]bool))
+
+	fields := make([]string, 0, len(c.fields))
+	for name := range c.fields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+type fieldCollector struct {
+	fields map[string]bool
+
+	// Inheritance chain (root parent first) of the template being walked,
+	// needed to look up the blocks
+	chain    []*Template
+	visiting map[*Template]bool
+}
+
+// withLocals returns a copy of the locals extended with the given names;
+// it's used for tags which open a new scope.
+func withLocals(locals map[string]bool, names ...string) map[string]bool {
+	scope := make(map[string]bool, len(locals)+len(names))
+	for name := range locals {
+		scope[name] = true
+	}
+	for _, name := range names {
+		scope[name] = true
+	}
+	return scope
+}
+
+func (c *fieldCollector) walkTemplate(tpl *Template, locals map[string]bool) {
+	if tpl == nil || c.visiting[tpl] {
+		return
+	}
+	c.visiting[tpl] = true
+	defer delete(c.visiting, tpl)
+
+	// Only the root parent gets executed, the children only provide blocks
+	var chain []*Template
+	for t := tpl; t != nil; t = t.parent {
+		chain = append([]*Template{t}, chain...)
+	}
+	outerChain := c.chain
+	c.chain = chain
+	c.walk(chain[0].root, locals)
+	c.chain = outerChain
+}
+
+func (c *fieldCollector) walkAll(locals map[string]bool, items ...interface{}) {
+	for _, item := range items {
+		c.walk(item, locals)
+	}
+}
+
+func (c *fieldCollector) walk(item interface{}, locals map[string]bool) {
+	switch n := item.(type) {
+	case nil:
+	case *nodeDocument:
+		if n != nil {
+			for _, node := range n.Nodes {
+				c.walk(node, locals)
+			}
+		}
+	case *NodeWrapper:
+		if n != nil {
+			for _, node := range n.nodes {
+				c.walk(node, locals)
+			}
+		}
+
+	// Expressions
+	case *nodeVariable:
+		c.walk(n.expr, locals)
+	case *nodeFilteredVariable:
+		c.walk(n.resolver, locals)
+		for _, filter := range n.filterChain {
+			c.walk(filter.parameter, locals)
+			for _, expr := range filter.moreParameters {
+				c.walk(expr, locals)
+			}
+			for _, expr := range filter.kwargs {
+				c.walk(expr, locals)
+			}
+		}
+	case *variableResolver:
+		if len(n.parts) > 0 && n.parts[0].typ == varTypeIdent {
+			name := n.parts[0].s
+			if !locals[name] && name != "pongo2" {
+				c.fields[name] = true
+			}
+		}
+		for _, part := range n.parts {
+			for _, arg := range part.callingArgs {
+				c.walk(arg, locals)
+			}
+		}
+	case *Expression:
+		c.walkAll(locals, n.expr1, n.expr2)
+	case *conditionalExpression:
+		c.walkAll(locals, n.trueExpr, n.condition, n.falseExpr)
+	case *relationalExpression:
+		c.walkAll(locals, n.expr1, n.expr2)
+		c.walkAll(locals, evaluatorsOf(n.chainExprs)...)
+	case *simpleExpression:
+		c.walkAll(locals, n.term1, n.term2)
+	case *term:
+		c.walkAll(locals, n.factor1, n.factor2)
+	case *power:
+		c.walkAll(locals, n.power1, n.power2)
+	case *listLiteral:
+		c.walkAll(locals, evaluatorsOf(n.items)...)
+	case *mapLiteral:
+		c.walkAll(locals, evaluatorsOf(n.keys)...)
+		c.walkAll(locals, evaluatorsOf(n.values)...)
+
+	// Tags introducing variables
+	case *tagForNode:
+		c.walk(n.objectEvaluator, locals)
+		c.walk(n.bodyWrapper, withLocals(locals, n.key, n.value, "forloop"))
+		c.walk(n.emptyWrapper, locals)
+	case *tagWithNode:
+		names := make([]string, 0, len(n.withPairs))
+		for name, expr := range n.withPairs {
+			c.walk(expr, locals)
+			names = append(names, name)
+		}
+		c.walk(n.wrapper, withLocals(locals, names...))
+	case *tagSetNode:
+		c.walkAll(locals, n.expression, n.wrapper)
+		locals[n.name] = true
+	case *tagAppendNode:
+		c.walk(n.expression, locals)
+		locals[n.name] = true
+	case *tagIncrementNode:
+		locals[n.name] = true
+	case *tagMacroNode:
+		for _, expr := range n.args {
+			c.walk(expr, locals)
+		}
+		locals[n.name] = true
+		c.walk(n.wrapper, withLocals(locals, n.argsOrder...))
+	case *tagImportNode:
+		for alias := range n.macros {
+			locals[alias] = true
+		}
+	case *tagCycleNode:
+		c.walkAll(locals, evaluatorsOf(n.args)...)
+		if n.asName != "" {
+			locals[n.asName] = true
+		}
+	case *tagWidthratioNode:
+		c.walkAll(locals, n.current, n.max, n.width)
+		if n.ctxName != "" {
+			locals[n.ctxName] = true
+		}
+
+	// Tags referencing other templates
+	case *tagBlockNode:
+		blockLocals := withLocals(locals, "block")
+		for _, t := range c.chain {
+			c.walk(t.blocks[n.name], blockLocals)
+		}
+	case *tagIncludeNode:
+		names := make([]string, 0, len(n.withPairs))
+		for name, expr := range n.withPairs {
+			c.walk(expr, locals)
+			names = append(names, name)
+		}
+		c.walk(n.filenameEvaluator, locals)
+		// Templates included with "only" don't see the context at all
+		if !n.only {
+			c.walkTemplate(n.tpl, withLocals(locals, names...))
+		}
+	case *tagSSINode:
+		c.walkTemplate(n.template, locals)
+
+	// Other tags with expressions or bodies
+	case *tagAutoescapeNode:
+		c.walk(n.wrapper, locals)
+	case *tagSpacelessNode:
+		c.walk(n.wrapper, locals)
+	case *tagFilterNode:
+		for _, call := range n.filterChain {
+			c.walk(call.paramExpr, locals)
+			for _, expr := range call.moreParamExprs {
+				c.walk(expr, locals)
+			}
+		}
+		c.walk(n.bodyWrapper, locals)
+	case *tagDefaultblockNode:
+		c.walkAll(locals, n.expr, n.wrapper)
+	case *tagFirstofNode:
+		c.walkAll(locals, evaluatorsOf(n.args)...)
+	case *tagIfNode:
+		c.walkAll(locals, evaluatorsOf(n.conditions)...)
+		for _, wrapper := range n.wrappers {
+			c.walk(wrapper, locals)
+		}
+	case *tagIfchangedNode:
+		c.walkAll(locals, evaluatorsOf(n.watchedExpr)...)
+		c.walkAll(locals, n.thenWrapper, n.elseWrapper)
+	case *tagIfEqualNode:
+		c.walkAll(locals, n.var1, n.var2, n.thenWrapper, n.elseWrapper)
+	case *tagIfNotEqualNode:
+		c.walkAll(locals, n.var1, n.var2, n.thenWrapper, n.elseWrapper)
+	case *tagJSONScriptNode:
+		c.walkAll(locals, n.value, n.id)
+	}
+}
+
+func evaluatorsOf(evaluators []IEvaluator) []interface{} {
+	items := make([]interface{}, 0, len(evaluators))
+	for _, e := range evaluators {
+		items = append(items, e)
+	}
+	return items
+}
@@ -8,8 +8,12 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // LocalFilesystemLoader represents a local filesystem loader with basic
@@ -211,3 +215,234 @@ func (h *HttpFilesystemLoader) Get(path string) (io.Reader, error) {
 
 	return h.fs.Open(fullPath)
 }
+
+// InMemoryLoader provides templates from memory (by name), like for tests
+// or templates generated at runtime. Includes, imports and extends resolve
+// among the templates of the loader; the names are used as they are.
+// It's safe for concurrent use. Be aware that a template set caches parsed
+// templates (unless in debug mode), so replacing a template with Add
+// doesn't affect already cached ones.
+type InMemoryLoader struct {
+	mutex     sync.RWMutex
+	templates map[string]string
+}
+
+// NewInMemoryLoader creates a new InMemoryLoader with the given templates
+// (name -> source; the map is copied and may be nil).
+func NewInMemoryLoader(templates map[string]string) *InMemoryLoader {
+	m := &InMemoryLoader{
+		templates: make(map[string]string, len(templates)),
+	}
+	for name, source := range templates {
+		m.templates[name] = source
+	}
+	return m
+}
+
+// Add adds a template (or replaces the one with the same name).
+func (m *InMemoryLoader) Add(name, source string) {
+	m.mutex.Lock()
+	m.templates[name] = source
+	m.mutex.Unlock()
+}
+
+// Abs returns the name unchanged; names are absolute keys.
+func (m *InMemoryLoader) Abs(base, name string) string {
+	return name
+}
+
+// Get returns the template's source; an unknown name results in an error
+// matching os.ErrNotExist.
+func (m *InMemoryLoader) Get(path string) (io.Reader, error) {
+	m.mutex.RLock()
+	source, has := m.templates[path]
+	m.mutex.RUnlock()
+	if !has {
+		return nil, &os.PathError{Op: "get", Path: path, Err: os.ErrNotExist}
+	}
+	return strings.NewReader(source), nil
+}
+
+// HTTPLoader loads templates over HTTP(S), for example from a remote
+// template service. Relative names are resolved against the URL of the
+// including template or (for the top-level templates) the base URL.
+//
+// Responses are cached: within the TTL (see SetTTL) a cached template is
+// used without any request, afterwards it's revalidated using its ETag and
+// Last-Modified headers (a 304 response keeps the cached template).
+// A 404 (or 410) response results in an error matching os.ErrNotExist,
+// 401 and 403 in one matching os.ErrPermission.
+//
+// Only templates on the scheme and host of the base URL are fetched (so the
+// headers, see SetHeader, are never sent elsewhere); others, like
+// {% include "https://example.com/x.tpl" %}, result in an error matching
+// os.ErrPermission. At most httpLoaderMaxCacheEntries templates are cached.
+type HTTPLoader struct {
+	baseURL *url.URL
+	client  *http.Client
+	header  http.Header
+	ttl     time.Duration
+
+	cacheMutex sync.Mutex
+	cache      map[string]*httpLoaderEntry
+}
+
+// httpLoaderMaxCacheEntries bounds the cache of an HTTPLoader; once it's
+// full, the least recently fetched template is dropped.
+const httpLoaderMaxCacheEntries = 1000
+
+type httpLoaderEntry struct {
+	content      []byte
+	etag         string
+	lastModified string
+	fetched      time.Time
+}
+
+// MustNewHTTPLoader creates a new HTTPLoader instance and panics if there's
+// any error during instantiation. The parameters are the same like
+// NewHTTPLoader.
+func MustNewHTTPLoader(baseURL string) *HTTPLoader {
+	loader, err := NewHTTPLoader(baseURL)
+	if err != nil {
+		log.Panic(err)
+	}
+	return loader
+}
+
+// NewHTTPLoader creates a new HTTPLoader loading templates relative to the
+// given (absolute) base URL, using http.DefaultClient. Like for directories,
+// the base URL should end with a slash.
+func NewHTTPLoader(baseURL string) (*HTTPLoader, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("The base URL '%s' is not absolute.", baseURL)
+	}
+	return &HTTPLoader{
+		baseURL: u,
+		client:  http.DefaultClient,
+		header:  make(http.Header),
+		cache:   make(map[string]*httpLoaderEntry),
+	}, nil
+}
+
+// SetClient sets the HTTP client used for the requests (for example one
+// with a timeout or a custom transport).
+func (h *HTTPLoader) SetClient(client *http.Client) {
+	h.client = client
+}
+
+// SetHeader sets a header sent with every request, like an authorization
+// header.
+func (h *HTTPLoader) SetHeader(key, value string) {
+	h.header.Set(key, value)
+}
+
+// SetTTL sets how long a cached template is used without revalidating it.
+// The default of 0 revalidates it on every Get; a negative TTL disables the
+// cache.
+func (h *HTTPLoader) SetTTL(ttl time.Duration) {
+	h.ttl = ttl
+}
+
+// Abs resolves the name against the URL of the including template (base)
+// or, if there's none, against the base URL. Absolute URLs are kept (but Get
+// refuses to fetch them if they're on another host).
+func (h *HTTPLoader) Abs(base, name string) string {
+	ref, err := url.Parse(name)
+	if err != nil {
+		return name
+	}
+	from := h.baseURL
+	if base != "" {
+		if b, err := url.Parse(base); err == nil && b.IsAbs() {
+			from = b
+		}
+	}
+	return from.ResolveReference(ref).String()
+}
+
+// Get fetches the template (or takes it from the cache, see HTTPLoader).
+func (h *HTTPLoader) Get(path string) (io.Reader, error) {
+	h.cacheMutex.Lock()
+	cached := h.cache[path]
+	h.cacheMutex.Unlock()
+
+	if cached != nil && time.Since(cached.fetched) < h.ttl {
+		return bytes.NewReader(cached.content), nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(req.URL.Scheme, h.baseURL.Scheme) || !strings.EqualFold(req.URL.Host, h.baseURL.Host) {
+		return nil, fmt.Errorf("get %s: %w (not on the host of the base URL)", path, os.ErrPermission)
+	}
+	for key, values := range h.header {
+		req.Header[key] = values
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		h.store(path, &httpLoaderEntry{
+			content:      cached.content,
+			etag:         cached.etag,
+			lastModified: cached.lastModified,
+			fetched:      time.Now(),
+		})
+		return bytes.NewReader(cached.content), nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, &os.PathError{Op: "get", Path: path, Err: os.ErrNotExist}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, &os.PathError{Op: "get", Path: path, Err: os.ErrPermission}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("get %s: unexpected response status '%s'", path, resp.Status)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	h.store(path, &httpLoaderEntry{
+		content:      content,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		fetched:      time.Now(),
+	})
+	return bytes.NewReader(content), nil
+}
+
+func (h *HTTPLoader) store(path string, entry *httpLoaderEntry) {
+	if h.ttl < 0 {
+		return
+	}
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+	if _, has := h.cache[path]; !has && len(h.cache) >= httpLoaderMaxCacheEntries {
+		oldest := ""
+		for p, e := range h.cache {
+			if oldest == "" || e.fetched.Before(h.cache[oldest].fetched) {
+				oldest = p
+			}
+		}
+		delete(h.cache, oldest)
+	}
+	h.cache[path] = entry
+}
@@ -6,11 +6,31 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"errors"
 )
 
+// EscapeMode defines which values get HTML-escaped automatically when
+// they're written to the output (as long as autoescaping is enabled).
+type EscapeMode int
+
+const (
+	// ModeEscapeAll escapes all values except those marked as safe (using
+	// AsSafeValue or the safe-filter). This is the default.
+	ModeEscapeAll EscapeMode = iota
+
+	// ModeEscapeNone never escapes values automatically.
+	ModeEscapeNone
+
+	// ModeEscapeMarked only escapes values explicitly marked as untrusted
+	// using AsUnsafeValue.
+	ModeEscapeMarked
+)
+
 // TemplateLoader allows to implement a virtual file system.
 type TemplateLoader interface {
 	// Abs calculates the path to a given template. Whenever a path must be resolved
@@ -53,6 +73,40 @@ type TemplateSet struct {
 	bannedTags           map[string]bool
 	bannedFilters        map[string]bool
 
+	// Set-local filters and tags, consulted before the global ones (see
+	// RegisterFilter() and RegisterTag())
+	filters map[string]FilterFunction
+	tags    map[string]*tag
+
+	// Context keys whose values are treated as safe (see SetSafeKeys())
+	safeKeys map[string]bool
+
+	// Which values are escaped automatically (see SetEscapeMode())
+	escapeMode EscapeMode
+
+	// Other sets available to the include-tag (see RegisterIncludeSet())
+	includeSets map[string]*TemplateSet
+
+	// Resolved names dynamic includes may load (nil = unrestricted, see
+	// SetIncludableTemplates())
+	includableTemplates map[string]bool
+
+	// Limits for templates loaded through the loaders (0 = unlimited)
+	maxIncludeDepth int
+	maxTemplateSize int64
+
+	// Don't provide the "pongo2" meta context (see SetInjectMeta())
+	hideMeta bool
+
+	// Reject the render filter (see SetRenderFilterEnabled())
+	disableRenderFilter bool
+
+	// Remove the final newline of the templates (see SetKeepTrailingNewline())
+	stripTrailingNewline bool
+
+	// Wall-clock budget for executing a template (0 = unlimited)
+	renderTimeout time.Duration
+
 	// Template cache (for FromCache())
 	templateCache      map[string]*Template
 	templateCacheMutex sync.Mutex
@@ -72,6 +126,8 @@ func NewSet(name string, loaders ...TemplateLoader) *TemplateSet {
 		Globals:       make(Context),
 		bannedTags:    make(map[string]bool),
 		bannedFilters: make(map[string]bool),
+		filters:       make(map[string]FilterFunction),
+		tags:          make(map[string]*tag),
 		templateCache: make(map[string]*Template),
 		Options:       newOptions(),
 	}
@@ -97,9 +153,185 @@ func (set *TemplateSet) resolveFilenameForLoader(loader TemplateLoader, tpl *Tem
 	return loader.Abs(name, path)
 }
 
+// SetSafeKeys marks the values of the given (top-level) context keys as safe,
+// so they won't get auto-escaped, just like values created with AsSafeValue().
+// Only use it for trusted content such as sanitized HTML fragments. Calling
+// SetSafeKeys again replaces the previously set keys.
+func (set *TemplateSet) SetSafeKeys(keys ...string) {
+	set.safeKeys = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set.safeKeys[key] = true
+	}
+}
+
+func (set *TemplateSet) isSafeKey(key string) bool {
+	return set.safeKeys[key]
+}
+
+// SetEscapeMode changes which values are HTML-escaped automatically (see
+// EscapeMode); the autoescape-tag still turns escaping off completely.
+//
+// Be aware of the security tradeoffs: ModeEscapeNone and ModeEscapeMarked
+// only make sense if (almost) all data passed to the templates is trusted.
+// With ModeEscapeMarked, any value not wrapped with AsUnsafeValue (for
+// example user input you forgot to mark, or the output of a filter applied
+// to a marked value) is written unescaped and may lead to XSS.
+func (set *TemplateSet) SetEscapeMode(mode EscapeMode) {
+	set.escapeMode = mode
+}
+
+// RegisterIncludeSet makes another template set available to the include-tag
+// of this set's templates under the given name:
+//
+//	{% include "widget.html" from "plugins" %}
+//
+// The included template is resolved, loaded and rendered by the other set
+// (with its loaders, globals and options) using the current template's
+// context. Register sets before creating templates which include from them.
+func (set *TemplateSet) RegisterIncludeSet(name string, other *TemplateSet) {
+	if set.includeSets == nil {
+		set.includeSets = make(map[string]*TemplateSet)
+	}
+	set.includeSets[name] = other
+}
+
+// SetIncludableTemplates restricts the templates which can be included
+// dynamically, where the filename is an expression evaluated at runtime:
+//
+//	{% include widget_name %}
+//
+// Including any other template fails with an error. Includes with a static
+// filename (string literal) aren't restricted. Calling it again replaces the
+// previous list; by default (or with no names), dynamic includes are
+// unrestricted. The list applies to dynamic includes from other sets (see
+// RegisterIncludeSet) as well, matching the names resolved by that set.
+func (set *TemplateSet) SetIncludableTemplates(names ...string) {
+	if len(names) == 0 {
+		set.includableTemplates = nil
+		return
+	}
+	set.includableTemplates = make(map[string]bool, len(names))
+	for _, name := range names {
+		set.includableTemplates[set.resolveFilename(nil, name)] = true
+	}
+}
+
+func (set *TemplateSet) isIncludable(filename string) bool {
+	return set.includableTemplates == nil || set.includableTemplates[set.resolveFilename(nil, filename)]
+}
+
+// SetMaxIncludeDepth limits how deeply templates may be nested through
+// include-, extends- and import-tags (e. g. to stop cyclic includes of
+// user-authored templates early). A depth of 0 (default) means unlimited.
+func (set *TemplateSet) SetMaxIncludeDepth(depth int) {
+	set.maxIncludeDepth = depth
+}
+
+// SetMaxTemplateSize limits the size (in bytes) of templates returned by the
+// loaders. A size of 0 (default) means unlimited.
+func (set *TemplateSet) SetMaxTemplateSize(size int64) {
+	set.maxTemplateSize = size
+}
+
+// RegisterFilter registers a filter only for this template set. Set-local
+// filters take precedence over the global ones (see pongo2.RegisterFilter),
+// so a set can use its own implementation for an existing filter name.
+// Filters are bound while parsing, so register them before you add the
+// templates which use them.
+func (set *TemplateSet) RegisterFilter(name string, fn FilterFunction) error {
+	if _, has := set.filters[name]; has {
+		return fmt.Errorf("filter with name '%s' is already registered in set '%s'", name, set.name)
+	}
+	set.filters[name] = fn
+	return nil
+}
+
+// RegisterTag registers a tag only for this template set. Set-local tags take
+// precedence over the global ones (see pongo2.RegisterTag). Like filters, tags
+// must be registered before adding the templates which use them.
+func (set *TemplateSet) RegisterTag(name string, parserFn TagParser) error {
+	if _, has := set.tags[name]; has {
+		return fmt.Errorf("tag with name '%s' is already registered in set '%s'", name, set.name)
+	}
+	set.tags[name] = &tag{
+		name:   name,
+		parser: parserFn,
+	}
+	return nil
+}
+
+// RegisterGlobal makes a value available to all templates of this set (by
+// adding it to Globals), like a helper function {{ asset("logo.png") }}.
+// Functions are called like functions within the context; a function may
+// return an error as second value, which fails the rendering. The render
+// context takes precedence over globals with the same name. Register the
+// globals before rendering, the set's Globals aren't synchronized.
+func (set *TemplateSet) RegisterGlobal(name string, value interface{}) error {
+	if !reIdentifiers.MatchString(name) {
+		return fmt.Errorf("global name '%s' is not a valid identifier", name)
+	}
+	if _, has := set.Globals[name]; has {
+		return fmt.Errorf("global with name '%s' is already registered in set '%s'", name, set.name)
+	}
+	set.Globals[name] = value
+	return nil
+}
+
+// filter looks up a filter in the set-local registry first, then globally.
+func (set *TemplateSet) filter(name string) (FilterFunction, bool) {
+	if fn, has := set.filters[name]; has {
+		return fn, true
+	}
+	return lookupFilter(name)
+}
+
+// tag looks up a tag in the set-local registry first, then globally.
+func (set *TemplateSet) tag(name string) (*tag, bool) {
+	if t, has := set.tags[name]; has {
+		return t, true
+	}
+	tagsMutex.RLock()
+	defer tagsMutex.RUnlock()
+	t, has := tags[name]
+	return t, has
+}
+
+// SetInjectMeta controls whether templates can access the "pongo2" meta
+// context (like {{ pongo2.version }}); it's enabled by default. Disable it
+// for user-provided templates which shouldn't be able to probe the version.
+func (set *TemplateSet) SetInjectMeta(inject bool) {
+	set.hideMeta = !inject
+}
+
+// SetRenderFilterEnabled controls whether the render filter, which parses and
+// renders a string as template (like {{ page.snippet|render }}), may be used;
+// it's enabled by default. Disable it if templates might apply it to
+// untrusted input, which would allow the input to execute arbitrary tags.
+func (set *TemplateSet) SetRenderFilterEnabled(enabled bool) {
+	set.disableRenderFilter = !enabled
+}
+
+// SetKeepTrailingNewline controls whether a single newline at the end of a
+// template's source is kept in the output; it's kept by default. Like
+// Jinja's keep_trailing_newline, disable it to remove the newline most
+// editors add at the end of a file. It applies to the templates parsed
+// afterwards.
+func (set *TemplateSet) SetKeepTrailingNewline(keep bool) {
+	set.stripTrailingNewline = !keep
+}
+
+// SetRenderTimeout limits the time executing a template (including all of
+// its includes) may take; the execution is aborted with an error once the
+// timeout is exceeded. The deadline is checked between nodes and loop
+// iterations, so a single slow function call won't be interrupted. A
+// timeout of 0 (default) disables the limit.
+func (set *TemplateSet) SetRenderTimeout(d time.Duration) {
+	set.renderTimeout = d
+}
+
 // BanTag bans a specific tag for this template set. See more in the documentation for TemplateSet.
 func (set *TemplateSet) BanTag(name string) error {
-	_, has := tags[name]
+	_, has := set.tag(name)
 	if !has {
 		return fmt.Errorf("tag '%s' not found", name)
 	}
@@ -117,7 +349,7 @@ func (set *TemplateSet) BanTag(name string) error {
 
 // BanFilter bans a specific filter for this template set. See more in the documentation for TemplateSet.
 func (set *TemplateSet) BanFilter(name string) error {
-	_, has := filters[name]
+	_, has := set.filter(name)
 	if !has {
 		return fmt.Errorf("filter '%s' not found", name)
 	}
@@ -143,7 +375,7 @@ func (set *TemplateSet) resolveTemplate(tpl *Template, path string) (name string
 		}
 	}
 
-	return path, nil, nil, fmt.Errorf("unable to resolve template")
+	return path, nil, nil, fmt.Errorf("unable to resolve template: %w", err)
 }
 
 // CleanCache cleans the template cache. If filenames is not empty,
@@ -208,28 +440,125 @@ func (set *TemplateSet) FromBytes(tpl []byte) (*Template, error) {
 	return newTemplateString(set, tpl)
 }
 
+// ParseCollectErrors loads and parses a template like FromFile, but instead
+// of stopping at the first parse error it skips the erroneous tag or
+// variable and continues, collecting all errors (sorted by position). It's
+// meant for tools like linters. Unless the template can't be loaded or
+// tokenized (then the template is nil), the partially parsed template is
+// returned as well; the erroneous elements are missing in its output.
+func (set *TemplateSet) ParseCollectErrors(filename string) (*Template, []*Error) {
+	buf, resolvedName, err := set.readTemplate(filename, nil, false)
+	if err != nil {
+		return nil, []*Error{err}
+	}
+	tpl, err := newLexedTemplate(set, filename, false, buf, nil, resolvedName)
+	if err != nil {
+		return nil, []*Error{err.withPhase(PhaseLex)}
+	}
+
+	tpl.collectErrors = true
+	if err := tpl.parse(); err != nil {
+		tpl.parseErrors = append(tpl.parseErrors, err.withPhase(PhaseParse))
+	}
+	tpl.collectErrors = false
+
+	errs := tpl.parseErrors
+	tpl.parseErrors = nil
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return tpl, errs
+}
+
 // FromFile loads a template from a filename and returns a Template instance.
 func (set *TemplateSet) FromFile(filename string) (*Template, error) {
+	return set.fromFile(filename, nil, false)
+}
+
+// fromFile loads a template which is included (through an include-, extends-
+// or import-tag) into includer. A template must not (directly or indirectly)
+// include itself while being parsed, since it would never finish; lazy
+// includes are evaluated during execution and may recurse (e. g. to render
+// trees), which is why only the include depth limit applies to them.
+func (set *TemplateSet) fromFile(filename string, includer *Template, lazy bool) (*Template, error) {
+	buf, resolvedName, err := set.readTemplate(filename, includer, lazy)
+	if err != nil {
+		return nil, err
+	}
+	return newTemplate(set, filename, false, buf, includer, resolvedName)
+}
+
+// readTemplate reads a template through the loaders, checking the limits
+// and (for non-lazy includes) include cycles.
+func (set *TemplateSet) readTemplate(filename string, includer *Template, lazy bool) ([]byte, string, *Error) {
 	set.firstTemplateCreated = true
 
-	_, _, fd, err := set.resolveTemplate(nil, filename)
+	if includer != nil && set.maxIncludeDepth > 0 && includer.depth+1 > set.maxIncludeDepth {
+		return nil, "", &Error{
+			Filename:  filename,
+			Sender:    "limits",
+			OrigError: fmt.Errorf("maximum include depth of %d exceeded", set.maxIncludeDepth),
+		}
+	}
+
+	resolvedName, _, fd, err := set.resolveTemplate(nil, filename)
 	if err != nil {
-		return nil, &Error{
+		return nil, "", &Error{
 			Filename:  filename,
 			Sender:    "fromfile",
 			OrigError: err,
 		}
 	}
+	if !lazy {
+		if chain := includeCycle(includer, resolvedName); chain != nil {
+			return nil, "", &Error{
+				Filename:  filename,
+				Sender:    "cyclecheck",
+				OrigError: fmt.Errorf("cyclic template reference: %s", strings.Join(chain, " -> ")),
+			}
+		}
+	}
+	if set.maxTemplateSize > 0 {
+		// Read one byte more than allowed to detect oversized templates
+		fd = io.LimitReader(fd, set.maxTemplateSize+1)
+	}
 	buf, err := ioutil.ReadAll(fd)
 	if err != nil {
-		return nil, &Error{
+		return nil, "", &Error{
 			Filename:  filename,
 			Sender:    "fromfile",
 			OrigError: err,
 		}
 	}
+	if set.maxTemplateSize > 0 && int64(len(buf)) > set.maxTemplateSize {
+		return nil, "", &Error{
+			Filename:  filename,
+			Sender:    "limits",
+			OrigError: fmt.Errorf("template exceeds the maximum size of %d bytes", set.maxTemplateSize),
+		}
+	}
 
-	return newTemplate(set, filename, false, buf)
+	return buf, resolvedName, nil
+}
+
+// includeCycle returns the chain of template names (ending with name) if
+// name is already being processed by one of the includers, otherwise nil.
+func includeCycle(includer *Template, name string) []string {
+	var chain []string
+	for tpl := includer; tpl != nil; tpl = tpl.includer {
+		if tpl.resolvedName == "" {
+			// Templates created from strings can't be part of a cycle
+			break
+		}
+		chain = append([]string{tpl.resolvedName}, chain...)
+		if tpl.resolvedName == name {
+			return append(chain, name)
+		}
+	}
+	return nil
 }
 
 // RenderTemplateString is a shortcut and renders a template string directly.
@@ -244,6 +573,26 @@ func (set *TemplateSet) RenderTemplateString(s string, ctx Context) (string, err
 	return result, nil
 }
 
+// RenderString parses the template source and renders it in one step, like
+// for one-off templates (previews, webhooks). The template is named name
+// (used for error messages) and resolves includes, imports and extends
+// relative to it like a template loaded from a file with that name would.
+// Unlike FromCache the template isn't cached. Parse and execution errors are
+// returned.
+func (set *TemplateSet) RenderString(name, source string, context Context) (string, error) {
+	set.firstTemplateCreated = true
+
+	resolvedName := name
+	if len(set.loaders) > 0 {
+		resolvedName = set.resolveFilename(nil, name)
+	}
+	tpl, err := newTemplate(set, name, false, []byte(source), nil, resolvedName)
+	if err != nil {
+		return "", err
+	}
+	return tpl.Execute(context)
+}
+
 // RenderTemplateBytes is a shortcut and renders template bytes directly.
 func (set *TemplateSet) RenderTemplateBytes(b []byte, ctx Context) (string, error) {
 	set.firstTemplateCreated = true
@@ -299,6 +648,7 @@ var (
 	FromCache            = DefaultSet.FromCache
 	RenderTemplateString = DefaultSet.RenderTemplateString
 	RenderTemplateFile   = DefaultSet.RenderTemplateFile
+	RegisterGlobal       = DefaultSet.RegisterGlobal
 
 	// Globals for the default set
 	Globals = DefaultSet.Globals
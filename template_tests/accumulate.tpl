@@ -0,0 +1,9 @@
+{% for item in simple.multiple_item_list %}{% increment counter %}{% if item > 10 %}{% increment big %}{% endif %}{{ counter }} {% endfor %}
+{{ counter }} {{ big }}
+{% increment number %}{{ number }} {{ number }}
+{% for item in simple.misc_list %}{% append collected item %}{% endfor %}{{ collected|join:", " }} ({{ collected|length }})
+{% for c in complex.comments %}{% append names c.Author.Name %}{% endfor %}{% for name in names %}{{ name }};{% endfor %}
+{% append simple_list 100 %}{{ simple_list|join:"," }}
+{% block content %}{% increment counter %}{% endblock %}{{ counter }}
+{% increment shadowed %}{% macro show(shadowed) %}{{ shadowed }}{% endmacro %}{{ show(99) }} {{ shadowed }}
+{% set local = 5 %}{% increment local %}{% for i in simple.multiple_item_list %}{% increment local %}{% endfor %}{{ local }}
\ No newline at end of file
@@ -0,0 +1 @@
+{% extends "assets_base.helper" %}{% block content %}{% addcss "/static/page.css" %}{% include "assets_widget.helper" %}{% include "assets_widget.helper" %}{% addcss "/static/page.css" %}{% endblock %}
\ No newline at end of file
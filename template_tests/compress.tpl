@@ -0,0 +1,15 @@
+{% compress %}
+<div id="content">
+    <p>
+        This   is a {{ "test" }}!   <!-- a comment -->   Mail me at
+        <a href="mailto:mail@example.tld">  mail@example.tld  </a>
+    </p>
+    <!--[if IE]>   <p>IE</p>   <![endif]-->
+    <PRE>
+  keep   this
+    as it is</pre>
+    <textarea>  a
+  b</textarea><script>var  a = "  x  ";</script>
+    <prefix>   done   </prefix>
+</div>
+{% endcompress %}
@@ -19,4 +19,8 @@
 '{% cycle "item1" simple.name simple.number as cycleitem silent %}'
 '{{ cycleitem }}'
 '{% cycle cycleitem %}'
-'{{ cycleitem }}'
\ No newline at end of file
+'{{ cycleitem }}'
+{% for row in simple.fixed_item_list %}{% for col in simple.fixed_item_list %}{% cycle "a" "b" "c" %} {% endfor %}{% resetcycle %}| {% endfor %}
+'{% cycle "a" "b" "c" as rowclass silent %}{% cycle rowclass %}{{ rowclass }}{% resetcycle rowclass %}{% cycle rowclass %}{{ rowclass }}'
+{% for item in simple.multiple_item_list %}{% cycle "x" "y" "z" %}{% if forloop.Counter == 2 %}{% resetcycle %}{% endif %}{% endfor %}
+{% for row in simple.fixed_item_list %}{% for col in [1, 2, 3] %}{% cycle "odd" "even" as rc silent %}{{ rc }} {% endfor %}{% resetcycle rc %}| {% endfor %}
\ No newline at end of file
@@ -0,0 +1,9 @@
+{% defaultblock simple.name %}<i>nobody</i>{% enddefaultblock %}
+{% defaultblock doesnotexist %}<i>nobody</i>{% enddefaultblock %}
+{% defaultblock simple.nil %}<p>{{ simple.name|upper }}</p>{% enddefaultblock %}
+{% defaultblock "" %}empty string{% enddefaultblock %}
+{% defaultblock simple.multiple_item_list|length %}no items{% enddefaultblock %}
+{% defaultblock simple.xss %}<i>no xss</i>{% enddefaultblock %}
+{% defaultblock simple.xss|safe %}<i>no xss</i>{% enddefaultblock %}
+{% autoescape off %}{% defaultblock simple.xss %}<i>no xss</i>{% enddefaultblock %}{% endautoescape %}
+{% for item in simple.misc_list %}{% defaultblock forloop.Counter0 %}first{% enddefaultblock %} {% endfor %}
\ No newline at end of file
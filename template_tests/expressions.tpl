@@ -66,4 +66,39 @@ issue #64 (uint comparison with int const)
 {{ simple.uint >= 8 }}
 {{ simple.uint <= 8 }}
 {{ simple.uint < 8 }}
-{{ simple.uint > 8 }}
\ No newline at end of file
+{{ simple.uint > 8 }}
+number literals
+{{ 1_000_000 }}
+{{ 1_000 + 1 }}
+{{ 0xFF }}
+{{ 0x_ff_ff }}
+{{ 0b1010 }}
+{{ 0o17 }}
+{{ 017 }}
+{{ 1_234.5 }}
+{{ 1_0 }} {{ 12_34 }} {{ 1_0000 + 1 }}
+{{ 0xFF == 255 }}
+
+scientific notation
+{{ 1e3 }}
+{{ 1e3 * 2 }}
+{{ 1e3 >= 1000 and 1e3 <= 1000 }}
+{{ 1.5e-3 }}
+{{ 2E+4 }}
+{{ 1_000e-3 }}
+
+conditional expressions
+{{ "active" if simple.bool_true else "offline" }}
+{{ "active" if simple.bool_false else "offline" }}
+'{{ "active" if simple.bool_false }}'
+{{ "yes" if simple.number > 40 and simple.name else "no" }}
+{{ "first" if simple.bool_false else "second" if simple.bool_true else "third" }}
+{{ "lazy" if true else simple.func_variadic_sum_int("foo") }}
+{{ simple.name|upper if simple.bool_true else "nobody" }}
+{{ (1 if simple.bool_false else 2) + 3 }}
+{% if "x" if simple.bool_true else "" %}truthy{% endif %}
+{% if 0 < simple.uint < 10 %}in range{% else %}out of range{% endif %}
+{% if 10 < simple.uint < 20 %}in range{% else %}out of range{% endif %}
+{{ 1 <= simple.uint < 9 <= 9 }} {{ 1 <= simple.uint < 8 <= 9 }} {{ 8 == simple.uint >= 8 }}
+{{ 1 < 2 == 3 }} {{ 3 > 2 > 1 }} {{ 3 > 2 < 1 }} {{ 0 < simple.uint + 1 < 10 and simple.bool_true }}
+{{ 5 < 1 < simple.func_variadic_sum_int("foo") }}
\ No newline at end of file
@@ -0,0 +1,3 @@
+{% extends "extends_super2.tpl" %}
+
+{% block content %}<div>{{ block.super }}</div>{% endblock %}
\ No newline at end of file
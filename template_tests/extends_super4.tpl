@@ -0,0 +1,3 @@
+{% extends "extends_super3.tpl" %}
+
+{% block content %}<section>{{ block.super }}{{ block.Super|length }}</section>{% endblock %}
\ No newline at end of file
@@ -9,6 +9,19 @@ add
 addslashes
 {{ "plain text"|addslashes|safe }}
 {{ simple.escape_text|addslashes|safe }}
+{{ "It's <ok>"|addslashes }}
+
+attr
+{{ simple|attr:"name" }}
+{{ complex.comments.0|attr:"Author.Name" }}
+{{ complex.comments.1|attr:"Author.Is_admin2" }}
+{{ simple|attr:"multiple_item_list.4" }}
+{{ complex.comments.0|attr:"Author.Unknown" }}
+{{ simple|attr:"nothing.at.all" }}
+
+base62encode/base62decode
+{{ 0|base62encode }} {{ 61|base62encode }} {{ 62|base62encode }} {{ simple.uint|base62encode }} {{ 1234567890123|base62encode }} {{ 9223372036854775807|base62encode }}
+{{ "LjaL3EZ"|base62decode }} {{ 9223372036854775807|base62encode|base62decode }} {{ 1000|base62encode|base62decode }}
 
 capfirst
 {{ ""|capfirst }}
@@ -17,9 +30,18 @@ capfirst
 {{ "hello there!"|capfirst }}
 {{ simple.chinese_hello_world|capfirst }}
 
+columns
+{% for col in simple.multiple_item_list|slice:":7"|columns:3 %}[{{ col|join:"," }}]{% endfor %}
+{% for col in simple.multiple_item_list|slice:":7"|columns:3:true %}[{{ col|join:"," }}]{% endfor %}
+{% for col in simple.multiple_item_list|slice:":2"|columns:3 %}[{{ col|join:"," }}]{% endfor %} {{ simple.multiple_item_list|columns:4|length }} {{ "text"|columns:2 }}
+
 cut
 {{ 15|cut:"5" }}
 {{ "Hello world"|cut: " " }}
+{{ "+49 (0) 30 1234"|cut:" " }}
+{{ "$1,000.00 $"|cut:"$" }}
+{{ "one, two, three"|cut:", " }}
+{{ "unchanged"|cut:"" }}
 
 default
 {{ simple.nothing|default:"n/a" }}
@@ -27,6 +49,81 @@ default
 {{ simple.number|default:"n/a" }}
 {{ 5|default:"n/a" }}
 
+date
+{{ 1402414215|date:"2006-01-02 15:04:05 MST" }}
+{{ "2014-06-10T15:30:15+02:00"|date:"Jan 2, 2006 15:04 -0700" }}
+{{ "2014-06-10"|time:"Monday" }}
+{{ simple.number|date:"2006" }}
+{{ simple.time1|date:"django:D d M Y H:i:s" }}
+{{ simple.time1|date:"django:l, jS \\o\\f F Y, P (e O)" }}
+{{ simple.time2|date:"django:y-n-j g A z W t L U c" }}
+
+intcomma/intword/ordinal/apnumber
+{{ 1234567|intcomma }} {{ simple.negative|intcomma }} {{ 123|intcomma }} {{ 1000|intcomma }} {{ 1234567.891|intcomma }} {{ simple.uint|intcomma }} {{ "text"|intcomma }}
+{{ 1200000|intword }} {{ 1000000|intword }} {{ simple.negative|intword }} {{ 999999|intword }} {{ 999950000|intword }} {{ 1.5e15|intword }}
+{{ 1|ordinal }} {{ 2|ordinal }} {{ 3|ordinal }} {{ 4|ordinal }} {{ 11|ordinal }} {{ 12|ordinal }} {{ 13|ordinal }} {{ 21|ordinal }} {{ 102|ordinal }} {{ 111|ordinal }} {{ simple.negative|ordinal }} {{ 3.0|ordinal }}
+{{ 1|apnumber }} {{ 4|apnumber }} {{ 9|apnumber }} {{ 0|apnumber }} {{ 12|apnumber }} {{ simple.uint|apnumber }} {{ 4.0|apnumber }} {{ "4"|apnumber }}
+
+numberformat
+{{ 1234567.891|numberformat }} {{ 1234567.891|numberformat:"en:2" }} {{ 1234567.891|numberformat:"de" }} {{ 1234567.891|numberformat:"2:,:." }} {{ 1234567.891|numberformat:2:",":"." }} {{ 1234567.891|numberformat:"de":1 }}
+{{ simple.negative|numberformat:"de:0" }} {{ 1234.5|numberformat:"ch:1" }} {{ 1234.5|numberformat:"3:.:" }} {{ 999.996|numberformat }} {{ "text"|numberformat }}
+
+timefmt
+{{ simple.time1|timefmt:"medium" }}
+{{ simple.time1|timefmt:"full" }}
+{{ 1402414215|timefmt:"short" }} / {{ "2014-06-10"|timefmt:"date" }} / {{ simple.time1|timefmt:"time" }} / {{ simple.time1|timefmt:"rfc3339" }}
+
+lookup
+{{ 5|lookup:simple.intmap }} {{ "2"|lookup:simple.intmap }} {{ 3|lookup:simple.intmap|default:"(missing)" }} {{ "abc"|lookup:simple.strmap }} {{ "x"|lookup:simple.strmap|default:"(missing)" }}
+{{ 1|lookup:simple.misc_list }} {{ "3"|lookup:simple.misc_list }} {{ 4|lookup:simple.misc_list|default:"(missing)" }} {{ "name"|lookup:{"name": "pongo2"} }}
+
+percentage
+{{ 0.1234|percentage:1 }} {{ 0.1234|percentage }} {{ 0.5|percentage:2 }} {{ 1|percentage }} {{ 0.125|percentage:"1" }} {{ simple.uint|percentage }}
+{{ 12.34|percentage:1:true }} {{ 45|percentage:0:true }} {{ 1.5|percentage:0:false }} {{ "text"|percentage:1 }}
+
+replace
+{{ "foo bar foo"|replace:"foo":"baz" }} {{ "foo bar foo"|replace:"foo":"baz":1 }} {{ "a-b-c"|replace:"-" }} {{ 12|replace:1:"x" }}
+{{ "a1b22c333"|replace:"/\\d+/":"#" }} {{ "a1b22c333"|replace:"/\\d+/":"#":2 }} {{ "2024-01-31"|replace:"/(\\d+)-(\\d+)-(\\d+)/":"$3.$2.$1" }} {{ "a1,b22"|replace:"/\\d{1,2}/":"_" }}
+{{ "a,b,c"|replace:",":";" }} {{ "a:b"|replace:":":"," }} {{ "abc"|replace:"":"x" }} {{ "abc"|replace:"//":"x" }} {{ simple.name|replace:simple.name:"x" }} {% filter replace:"o":"0":1 %}foo{% endfilter %}
+{{ "a,b,c"|replace(old=",", new=";") }} {{ "a,b,c"|replace(old=",", new=";", count=1) }} {{ "x1y2"|replace(old="[0-9]", new="", regex=true) }}
+
+mask
+{{ "4111111111111234"|mask:4 }} {{ "4111111111111234"|mask:4:"#" }} {{ "jane.doe@example.com"|mask:4:"*":true }} {{ "äöüß€"|mask:2 }}
+{{ "123"|mask:4 }} {{ "1234"|mask:4 }} {{ ""|mask:4 }} {{ "secret"|mask:0 }} {{ 12345|mask:1:"x" }} {{ "abc"|mask:1:"," }}
+
+naturaltime
+{{ simple.time1|naturaltime:simple.time1 }}
+{{ "2014-06-10T15:29:45Z"|naturaltime:simple.time1 }}
+{{ "2014-06-10T15:29:15Z"|naturaltime:simple.time1 }}
+{{ "2014-06-10T12:10:00Z"|naturaltime:simple.time1 }}
+{{ "2014-06-10T16:30:15Z"|naturaltime:simple.time1 }}
+{{ "2014-06-12T18:00:00Z"|naturaltime:simple.time1 }}
+{{ "2013-01-01"|naturaltime:simple.time1 }}
+{{ simple.time2|naturaltime:simple.time1 }}
+
+highlight
+{{ "Go templates with pongo2"|highlight:"pongo" }}
+{{ "Pongo2 is a <b>Django</b>-like template engine"|highlight:"django TEMPLATE" }}
+{{ "abcdef"|highlight:"abc cde" }}
+{{ "abcdef"|highlight:"ab cd" }}
+{{ "no match & more"|highlight:"xyz" }}
+{{ "text"|highlight:"" }}
+
+startswith/endswith/contains
+{% if "/admin/users"|startswith:"/admin" %}admin{% endif %}
+{{ "/admin/users"|startswith:"/Admin" }} {{ "/admin/users"|startswith:"/Admin":true }} {{ "/admin/users"|startswith:"/Admin":false }}
+{{ "report.PDF"|endswith:".pdf" }} {{ "report.PDF"|endswith:".pdf":true }} {{ "report.PDF"|endswith:".PDF" }}
+{{ "Hello World"|contains:"o W" }} {{ "Hello World"|contains:"o w" }} {{ "Hello World"|contains:"o w":true }} {{ "a,b"|contains:"," }}
+{{ simple.misc_list|contains:"Hello" }} {{ simple.misc_list|contains:99 }} {{ simple.misc_list|contains:"hello" }} {{ simple.misc_list|contains:"hello":true }} {{ simple.misc_list|contains:"Hell":true }}
+
+coalesce
+{% with empty=simple.multiple_item_list|slice:":0" %}{{ nothing|coalesce:""|coalesce:empty|coalesce:"Anonymous" }}{% endwith %}
+{{ simple.nothing|coalesce:simple.name|coalesce:"Anonymous" }}
+{{ simple.name|coalesce:"Anonymous" }}
+{% with empty=simple.multiple_item_list|slice:":0" %}{{ nothing|coalesce:"":empty:simple.nothing:"Anonymous" }} {{ ""|coalesce:empty:simple.name:"Anonymous" }} {{ nothing|coalesce:empty:"" }}|{% endwith %}
+{{ 0|coalesce:"Anonymous" }}
+{{ simple.bool_false|coalesce:"Anonymous" }}
+
 default_if_none
 {{ simple.nothing|default_if_none:"n/a" }}
 {{ ""|default_if_none:"n/a" }}
@@ -39,6 +136,10 @@ get_digit
 {{ 1234567890|get_digit:"4" }}
 {{ 1234567890|get_digit:10 }}
 {{ 1234567890|get_digit:15 }}
+{{ 12345|get_digit:2 }}
+{{ "12345"|get_digit:1 }}
+{{ "abc"|get_digit:2 }}
+{{ 12.5|get_digit:1 }}
 
 safe
 {{ "<script>" }}
@@ -48,6 +149,21 @@ escape
 {{ "<script>"|safe|escape }}
 {{ "<script>"|safe|e }}
 
+escape_once
+{{ "Tom &amp; Jerry & <friends>"|escape_once }}
+{{ "&lt;b&gt; &#39;quoted&#x27; &copy;"|escape_once }}
+{{ "a & b"|escape|escape_once }}
+
+force_escape
+{{ "<b>bold</b>"|safe|force_escape }}
+{{ simple.xss|force_escape }}
+{{ "&amp;"|force_escape }}
+
+unescape
+{{ "Tom &amp; Jerry &lt;3 &quot;cheese&quot; &#39;n&#x27; &copy;"|unescape|safe }}
+{{ "&lt;b&gt;"|unescape }}
+{{ "<b>"|escape|unescape|safe }}
+
 title
 {{ ""|title }}
 {{ 5|title }}
@@ -64,6 +180,30 @@ truncatechars
 {{ simple.chinese_hello_world|truncatechars:1 }}
 {{ simple.chinese_hello_world|truncatechars:2 }}
 
+truncate
+{{ "Joel is a slug"|truncate:14 }}
+{{ "Joel is a slug"|truncate:12 }}
+{{ "Joel is a slug"|truncate:12:true }}
+{{ "Joel is a slug"|truncate:11:false:"…" }}
+{{ "Joel is a slug"|truncate:11:true:" [more]" }}
+{{ simple.chinese_hello_world|truncate:3:true:"…" }}
+{{ "Joel is a slug"|truncate:9:true:", …" }}
+{{ "Joel is a slug"|truncate(end="…", length=11) }}
+{{ "Joel is a slug"|truncate(killwords=true, length=12)|upper }}
+
+truncate_middle
+{{ "/usr/local/share/pongo2/templates/base.html"|truncate_middle:20 }}
+{{ "/usr/local/share/pongo2/templates/base.html"|truncate_middle:20:"…" }} {{ "/usr/local/share/pongo2/templates/base.html"|truncate_middle:20:", …, " }}
+{{ "/etc/hosts"|truncate_middle:20 }}
+{{ simple.chinese_hello_world|truncate_middle:3:"~" }}
+
+truncate_sentences
+{{ "First sentence. Second one! Is there a third?"|truncate_sentences:2 }}
+{{ "First sentence. Second one! Is there a third?"|truncate_sentences:3 }}|{{ "One. Two.  "|truncate_sentences:2 }}|{{ "One. Two."|truncate_sentences:0 }}|
+{{ "J. R. R. Tolkien wrote it, e.g. in 1937. Dr. Who met Mr. Smith. The end."|truncate_sentences:2 }}
+{{ "He said \"Stop.\" Then he left... Later (much later.) it rained?! Yes."|truncate_sentences:3 }}
+{{ "Version 1.5 is out. Really. Go"|truncate_sentences:1 }}
+
 divisibleby
 {{ 21|divisibleby:3 }}
 {{ 21|divisibleby:"3" }}
@@ -71,6 +211,7 @@ divisibleby
 {{ 22|divisibleby:"3" }}
 {{ 85|divisibleby:simple.number }}
 {{ 84|divisibleby:simple.number }}
+{% for i in simple.fixed_item_list %}{% if forloop.Counter|divisibleby:2 %}{{ i }}{% endif %}{% endfor %}
 
 striptags
 {{ "<strong><i>Hello!</i></strong>"|striptags|safe }}
@@ -87,6 +228,13 @@ yesno
 {{ simple.bool_false|yesno:"ja,nein,vielleicht" }}
 {{ simple.nothing|yesno:"ja,nein" }}
 
+pluck
+{{ complex.comments|pluck:"Author.Name"|join:", " }}
+{{ [{"name": "a", "address": {"city": "Berlin"} }, {"name": "b"}, {"name": "c", "address": {"city": "Rome"} }]|pluck:"address.city"|join:", " }}
+{{ [{"name": "a", "address": {"city": "Berlin"} }, {"name": "b"}, {"name": "c", "address": {"city": "Rome"} }]|pluck:"address.city":true|length }}
+{% for city in [{"address": {"city": "Berlin"} }, {"name": "b"}]|pluck:"address.city":true %}{{ city|default:"(none)" }} {% endfor %}
+{{ complex.comments|pluck:"Author.Unknown"|length }} {{ complex.comments|pluck:"Author.Unknown":true|length }} {{ "text"|pluck:"x" }}
+
 pluralize
 customer{{ 0|pluralize }}
 customer{{ 1|pluralize }}
@@ -98,6 +246,12 @@ walrus{{ 0|pluralize:"es" }}
 walrus{{ 1|pluralize:"es" }}
 walrus{{ simple.number|pluralize:"es" }}
 
+pprint
+{{ simple.intmap|pprint }}
+{{ simple.one_item_list|pprint }}
+{{ simple.nil|pprint }}
+{{ complex.comments.0.Author|pprint }}
+
 random
 {{ 5|random }}
 {{ ""|random }}
@@ -111,6 +265,9 @@ first
 {{ true|first }}
 {{ nothing|first }}
 {{ simple.chinese_hello_world|first }}
+{{ simple.strmap|first }}
+{{ simple.intmap|first }}
+{{ ""|first|default:"empty" }}
 
 last
 {{ "Test"|last }}
@@ -119,24 +276,33 @@ last
 {{ true|last }}
 {{ nothing|last }}
 {{ simple.chinese_hello_world|last }}
+{{ simple.strmap|last }}
+{{ simple.intmap|last }}
+{{ ""|last|default:"empty" }}
 
 urlencode
 {{ "http://www.example.org/foo?a=b&c=d"|urlencode }}
+{{ "a value/with slashes"|urlencode }}
+{{ "/docs/a file/über?.txt"|urlencode:"path" }}
 
 linebreaksbr
 {{ simple.newline_text|linebreaksbr }}
 {{ ""|linebreaksbr }}
 {{ "hallo"|linebreaksbr }}
+{{ simple.br_text|linebreaksbr }}
+{{ simple.br_text|linebreaksbr|linebreaksbr }}
 
 length_is
 {{ simple.name|length_is:8 }}
 {{ simple.name|length_is:10 }}
 {{ simple.name|length_is:"8" }}
 {{ simple.name|length_is:"10" }}
-{{ 5|length_is:1 }}
 {{ simple.chinese_hello_world|length_is:4 }}
 {{ simple.chinese_hello_world|length_is:3 }}
 {{ simple.chinese_hello_world|length_is:5 }}
+{{ simple.multiple_item_list|length_is:10 }}
+{{ simple.multiple_item_list|length_is:9 }}
+{{ simple.strmap|length_is:6 }}
 
 integer
 {{ "foobar"|integer }}
@@ -154,9 +320,22 @@ float
 {{ 5|float }}
 {{ "5.6"|integer|float }}
 {{ -100|float }}
+{{ "foobar"|float:1.5 }} {{ nil|float:"n/a" }} {{ " 2.5 "|float:1.5 }} {{ 3|float:1.5 }}
 {% if 5.5 == 5.500000 %}5.5 is 5.500000{% endif %}
 {% if 5.5 != 5.500001 %}5.5 is not 5.500001{% endif %}
 
+int
+{{ "42"|int }} {{ " 42 "|int + 1 }} {{ "4.7"|int }} {{ 4.7|int }} {{ simple.negative|int }} {{ simple.bool_true|int }} {{ simple.uint|int }}
+{{ "foobar"|int:0 }} {{ nothing|int:"-" }} {{ "x"|int:"n/a" }} {{ "7"|int:0 }}
+
+bool
+{{ "true"|bool }} {{ "TRUE"|bool }} {{ "1"|bool }} {{ "false"|bool }} {{ "False"|bool }} {{ "0"|bool }}
+{{ "yes"|bool }} {{ ""|bool }} {{ 0|bool }} {{ 2|bool }} {{ nothing|bool }} {{ simple.multiple_item_list|bool }}
+{% if "false"|bool %}wrong{% else %}"false" is false{% endif %}
+
+string
+{{ 42|string }} {{ 4.5|string }} {{ 42|string|length }} {{ simple.bool_true|string }} {{ "<b>"|string }} {{ "<b>"|safe|string }}
+
 floatformat
 {{ 34.23234|floatformat }}
 {{ 34.00000|floatformat }}
@@ -176,6 +355,35 @@ floatformat
 
 join
 {{ simple.misc_list|join:", " }}
+{{ complex.comments|join:", ":"Author.Name" }}
+{{ complex.comments|join(sep=", ", attr="Author.Name") }}
+{{ complex.comments|join(attr="Author.Name") }}
+{{ complex.comments|join(sep=" / ", attr="Author.Missing") }}
+{{ simple.misc_list|join:" : " }}
+{{ simple.misc_list|join:"a:b" }}
+{{ simple.misc_list|join:"key:value" }}
+{{ complex.comments|join:"a:b":"Author.Name" }}
+
+items
+{% for kv in simple.strmap|items %}{{ kv.key }}={{ kv.value }} {% endfor %}
+{% for kv in simple.intmap|items %}{{ kv.key }}={{ kv.value }} {% endfor %}
+
+keys
+{{ simple.strmap|keys|join:", " }}
+{{ simple.intmap|keys|join:", " }}
+
+values
+{{ simple.strmap|values|join:", " }}
+{{ simple.intmap|values|join:", " }}
+
+sort
+{{ simple.unsorted_int_list|sort|join:", " }}
+{{ simple.strmap|values|sort|join:", " }}
+{{ simple.misc_list|sort|join:", " }}
+{{ ["x", 10, 1.5, 9, "5"]|sort|join:"," }} {{ [9, "5", "x", 1.5, 10]|sort|join:"," }} {{ ["x", 10, 1.5, 9, "5"]|sort_reversed|join:"," }}
+{% for c in complex.comments2|sort:"Author.Name" %}{{ c.Author.Name }}/{{ c.Date|date:"2006" }} {% endfor %}
+{{ simple.unsorted_int_list|sort_reversed|join:", " }}
+{% for c in complex.comments2|sort_reversed:"Author.Name" %}{{ c.Author.Name }}/{{ c.Date|date:"2006" }} {% endfor %}
 
 split
 {{ "Hello, 99, 3.140000, good"|split:", "|join:", " }}
@@ -198,21 +406,39 @@ center
 '{{ "test2"|center:20 }}'
 {{ "test2"|center:20|length }}
 '{{ simple.chinese_hello_world|center:20 }}'
+'{{ "hi"|center:7:"*" }}'
+'{{ simple.chinese_hello_world|center:6:"-" }}'
 
 ljust
 '{{ "test"|ljust:"2" }}'
 '{{ "test"|ljust:"20" }}'
 {{ "test"|ljust:"20"|length }}
 '{{ simple.chinese_hello_world|ljust:10 }}'
+'{{ "test"|ljust:8:"." }}' '{{ "ab"|ljust:4:"," }}'
+'{{ simple.chinese_hello_world|ljust:6:"好" }}'
+
+reverse
+{{ simple.multiple_item_list|reverse|join:", " }}
+{{ simple.multiple_item_list|join:", " }}
+{{ "abc"|reverse }}
+{{ "你好世界"|reverse }}
+{{ ""|reverse }}
 
 rjust
 '{{ "test"|rjust:"2" }}'
 '{{ "test"|rjust:"20" }}'
 {{ "test"|rjust:"20"|length }}
 '{{ simple.chinese_hello_world|rjust:10 }}'
+'{{ "test"|rjust:8:0 }}'
+'{{ "test"|rjust:2:"0" }}'
 
 wordcount
 {{ ""|wordcount }}
+{{ "   "|wordcount }}
+{{ "The quick  brown fox."|wordcount }}
+{{ "  leading and trailing   spaces  "|wordcount }}
+{{ simple.chinese_hello_world|wordcount }}
+{{ "Hello 世界!"|wordcount }}
 {% filter wordcount %}{% lorem 25 w %}{% endfilter %}
 
 wordwrap
@@ -279,6 +505,16 @@ Please mail me at demo@example.com or visit mit on:
 escapejs
 {{ simple.escape_js_test|escapejs|safe }}
 
+escapejson
+<div data-config="{{ simple.misc_list|escapejson }}"></div>
+<div data-config='{{ "it's <b>"|escapejson:"single" }}'></div>
+<div data-config="{{ "it's <b>"|escapejson:"double" }}"></div>
+
+json_canonical
+{{ simple.strmap|json_canonical|safe }}
+{{ complex.comments.0|json_canonical }}
+{{ simple.misc_list|json_canonical|safe }} {{ 1.50|json_canonical }} {{ "<b>"|json_canonical|safe }}
+
 slice
 {{ simple.multiple_item_list|slice:":99"|join:"," }}
 {{ simple.multiple_item_list|slice:"99:"|join:"," }}
@@ -289,6 +525,13 @@ slice
 {{ simple.multiple_item_list|slice:"2:1"|join:"," }}
 {{ "Test"|slice:"1:3" }}
 {{ simple.chinese_hello_world|slice:"1:3" }}
+{{ simple.multiple_item_list|slice:"1:5:2"|join:"," }}
+{{ simple.multiple_item_list|slice:"::-1"|join:"," }}
+{{ simple.multiple_item_list|slice:"-3:"|join:"," }}
+{{ simple.multiple_item_list|slice:"-3:-1"|join:"," }}
+{{ simple.multiple_item_list|slice:"-2::-3"|join:"," }}
+{{ simple.multiple_item_list|slice:"-99:2"|join:"," }}
+{{ "Hello"|slice:"::-1" }} {{ "Hello"|slice:"::2" }} {{ simple.chinese_hello_world|slice:"::-1" }}
 
 truncatechars_html
 {{ "This is a long test which will be cutted after some chars."|truncatechars_html:25 }}
@@ -306,3 +549,12 @@ truncatewords_html
 {{ "<p>This </a>is a long test, which will be cutted after some words.</p>"|truncatewords_html:5 }}
 {{ "<p>This is a long test which will be cutted after some words.</p>"|truncatewords_html:2 }}
 {{ "<p>This is a long test which will be cutted after some words.</p>"|truncatewords_html:0 }}
+{{ "<p>one <b>two three</b> four</p>"|truncatewords_html:2 }}
+{{ "<p>one <br>two <img src=\"x.png\"/> three <hr/>four</p>"|truncatewords_html:3 }}
+
+zip
+{% for key, value in ["a", "b", "c"]|zip:simple.multiple_item_list %}{{ key }}={{ value }} {% endfor %}
+{% for key, value in ["a", "b", "c"]|zip:[1, 2, 3] reversed %}{{ key }}={{ value }} {% endfor %}
+{% for key, value in ["b", "c", "a"]|zip:[1, 2, 3] sorted %}{{ forloop.Counter }}.{{ key }}={{ value }} {% endfor %}
+{% for pair in ["a", "b"]|zip:[1, 2] %}{{ pair }} {% endfor %}{% for key in []|zip:[1] %}{% empty %}empty{% endfor %}
+{{ ["a", "b", "c"]|zip:[1]|length }} {{ ["a", "b"]|zip:[1, 2]|first|join:"=" }} {{ ["a", "b"]|zip:[1, 2]|last|last }}
@@ -25,3 +25,12 @@ reversed sorted string map
 
 reversed sorted int map
 '{% for key in simple.intmap reversed sorted %}{{ key }} {% endfor %}'
+
+key and value of a sorted map
+'{% for key, value in simple.strmap sorted %}{{ key }}={{ value }} {% endfor %}'
+
+index and item of a list
+'{% for i, item in simple.multiple_item_list %}{{ i }}:{{ item }} {% endfor %}'
+
+index and item of a reversed list
+'{% for i, item in simple.fixed_item_list reversed %}{{ i }}:{{ item }} {% endfor %}'
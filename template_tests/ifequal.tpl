@@ -0,0 +1,9 @@
+{% ifequal simple.number 42 %}equal{% endifequal %}
+{% ifequal simple.number 43 %}equal{% else %}not equal{% endifequal %}
+{% ifequal simple.name "john doe" %}john{% else %}someone else{% endifequal %}
+{% ifequal simple.uint simple.number %}equal{% else %}not equal{% endifequal %}
+{% ifequal simple.number|add:1 43 %}43{% endifequal %}
+{% ifequal nothing "" %}equal{% else %}nil != ""{% endifequal %}
+{% ifnotequal simple.number 43 %}not equal{% endifnotequal %}
+{% ifnotequal simple.number 42 %}not equal{% else %}equal{% endifnotequal %}
+{% ifnotequal simple.name "john doe" %}someone else{% else %}john{% endifnotequal %}
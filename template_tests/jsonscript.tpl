@@ -0,0 +1,4 @@
+{% json_script simple.xss "xss-data" %}
+{% json_script simple.strmap "map-data" %}
+{% json_script simple.multiple_item_list simple.name %}
+{% json_script "a & b " "<id>" %}
\ No newline at end of file
@@ -0,0 +1,7 @@
+{{ [1, 2, 3]|join:"," }}
+{{ []|length }} {{ ["a", simple.name, 1 + 2, ]|join:"-" }} {{ [[1, 2], [3]]|length }} {{ [1, 2, 3]|last }}
+{% for k, v in {"x": 1, "y": simple.number, "z": [1, 2]|join:"+"} sorted %}{{ k }}={{ v }} {% endfor %}
+{% for item in ["<b>", simple.name|upper] %}{{ forloop.Counter }}:{{ item }} {% endfor %}
+{{ {"name": simple.name, "na"|add:"me": 1}|attr:"name" }} {{ {}|length }} {{ {1: "one"}|keys|first }}
+{{ 2 in [1, 2, 3] }} {{ 4 in [1, 2, 3] }} {{ "b" in {"a": 1, "b": 2} }}
+{% with items=["x", "y"] %}{{ items|length }}{% endwith %} {{ simple.number|default:[1] }}
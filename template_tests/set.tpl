@@ -3,4 +3,5 @@
 {{ new_var }}{% for item in simple.misc_list %}
 {% set new_var = item %}{{ new_var }}{% endfor %}
 {{ new_var }}
-{% set car=someUndefinedVar %}{{ car.Drive }}No Panic
\ No newline at end of file
+{% set car=someUndefinedVar %}{{ car.Drive }}No Panic
+{% set what_am_i = "captured" %}{% set nav %}<{% include "includes.helper" %}>{% endset %}{% set what_am_i = "changed" %}{{ nav }} {{ nav }}
\ No newline at end of file
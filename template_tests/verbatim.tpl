@@ -4,4 +4,10 @@
 {% test %}
 {% endverbatim %}{{ simple.number }}.
 
-.{{ simple.number }}{% verbatim %}{{ test }}{% endverbatim %}{{ simple.number }}.
\ No newline at end of file
+.{{ simple.number }}{% verbatim %}{{ test }}{% endverbatim %}{{ simple.number }}.
+
+.{{ simple.number }}{% raw %}
+{% if user.online %}{{ x }}{% endif %}
+{% verbatim %}{% endverbatim %}
+{% endraw %}{{ simple.number }}.
+.{% verbatim %}{% raw %}{% endraw %}{% endverbatim %}.{% raw %}{% verbatim %}{% endverbatim %}{% endraw %}.
\ No newline at end of file
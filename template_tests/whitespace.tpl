@@ -0,0 +1,38 @@
+{# Whitespace between tags is removed by spaceless #}
+{% spaceless %}<p>
+    <b>text  stays</b>
+</p>{% endspaceless %}
+{# ... but not within verbatim-tags #}
+{% spaceless %}<p>
+    {% verbatim %}<b>  a  </b>   <i>{{ b }}</i>{% endverbatim %}
+</p>{% endspaceless %}
+{# ... and not within safe values; next to tags of them it's removed #}
+{% spaceless %}<div>
+    {{ "<b>a</b>   <i>b</i>"|safe }}
+    {{ "text   </i>"|safe }}
+    <p>  </p>
+</div>{% endspaceless %}
+{# Escaped values are processed #}
+{% spaceless %}<div>   {{ "<b>a</b>   <i>b</i>" }}   </div>{% endspaceless %}
+{# Tags within verbatim-tags are text #}
+{% verbatim %}{% spaceless %}<a>  <b>{% endspaceless %}{% endverbatim %}
+{# Included templates are processed, but not their verbatim content #}
+{% spaceless %}<div>
+    {% include "whitespace_include.helper" %}
+</div>{% endspaceless %}
+{# The same holds for compress #}
+{% compress %}<p>
+    run   of   text {% verbatim %}  <b>  a  </b>  {% endverbatim %} {{ "<i>  b  </i>"|safe }}
+</p>{% endcompress %}
+{# Nested whitespace tags keep the protected text #}
+{% spaceless %}<div>
+    {% compress %}<p>  {% verbatim %}<b>  a  </b>{% endverbatim %}  </p>{% endcompress %}
+    <p>  </p>
+</div>{% endspaceless %}
+{# Internally safe values, like the output of macros, are processed #}
+{% set captured %}<b>   captured   </b>{% endset %}{% compress %}<p>  {{ captured }}  </p>{% endcompress %}
+{% macro boxed() %}<p>
+    <b>x</b>
+</p>{% endmacro %}{% spaceless %}<div>
+ {{ boxed() }}
+</div>{% endspaceless %}
\ No newline at end of file
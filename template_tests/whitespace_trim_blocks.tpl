@@ -0,0 +1,8 @@
+{% if true %}
+    {% verbatim %}
+    kept
+    {% endverbatim %}
+{% endif %}
+{% if true %}
+trimmed
+    {% endif %}
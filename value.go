@@ -1,6 +1,7 @@
 package pongo2
 
 import (
+	"encoding"
 	"fmt"
 	"reflect"
 	"sort"
@@ -10,8 +11,9 @@ import (
 )
 
 type Value struct {
-	val  reflect.Value
-	safe bool // used to indicate whether a Value needs explicit escaping in the template
+	val    reflect.Value
+	safe   bool // used to indicate whether a Value needs explicit escaping in the template
+	unsafe bool // marks a Value to be escaped when using ModeEscapeMarked
 }
 
 // AsValue converts any given value to a pongo2.Value
@@ -34,6 +36,16 @@ func AsSafeValue(i interface{}) *Value {
 	}
 }
 
+// AsUnsafeValue works like AsValue, but marks the value as untrusted. With
+// the escape mode ModeEscapeMarked (see TemplateSet.SetEscapeMode), only
+// values marked this way get escaped by the template engine.
+func AsUnsafeValue(i interface{}) *Value {
+	return &Value{
+		val:    reflect.ValueOf(i),
+		unsafe: true,
+	}
+}
+
 func (v *Value) getResolvedValue() reflect.Value {
 	if v.val.IsValid() && v.val.Kind() == reflect.Ptr {
 		return v.val.Elem()
@@ -99,14 +111,24 @@ func (v *Value) IsNil() bool {
 //     4. bool
 //     5. time.Time
 //     6. String() will be called on the underlying value if provided
+//     7. Error() will be called on errors
+//     8. MarshalText() will be called on encoding.TextMarshalers
 //
-// NIL values will lead to an empty string. Unsupported types are leading
-// to their respective type name.
+// The methods (6.-8.) take precedence for types other than the built-in
+// ones, like time.Duration or enums based on int. NIL values will lead to
+// an empty string. Unsupported types are leading to their respective type
+// name.
 func (v *Value) String() string {
 	if v.IsNil() {
 		return ""
 	}
 
+	if !v.isBuiltinType() {
+		if text, ok := v.methodText(); ok {
+			return text
+		}
+	}
+
 	switch v.getResolvedValue().Kind() {
 	case reflect.String:
 		return v.getResolvedValue().String()
@@ -121,16 +143,54 @@ func (v *Value) String() string {
 			return "True"
 		}
 		return "False"
-	case reflect.Struct:
-		if t, ok := v.Interface().(fmt.Stringer); ok {
-			return t.String()
-		}
 	}
 
 	logf("Value.String() not implemented for type: %s\n", v.getResolvedValue().Kind().String())
 	return v.getResolvedValue().String()
 }
 
+// isBuiltinType returns whether the (resolved) value is of a predeclared
+// type like int or string, which can't have methods.
+func (v *Value) isBuiltinType() bool {
+	rv := v.getResolvedValue()
+	return rv.Type().PkgPath() == "" && rv.Type().Name() != "" && v.val.Kind() != reflect.Ptr
+}
+
+// methodText returns the text provided by String(), Error() or MarshalText().
+func (v *Value) methodText() (string, bool) {
+	if !v.val.CanInterface() {
+		return "", false
+	}
+	switch t := v.Interface().(type) {
+	case fmt.Stringer:
+		return t.String(), true
+	case error:
+		return t.Error(), true
+	case encoding.TextMarshaler:
+		if text, err := t.MarshalText(); err == nil {
+			return string(text), true
+		}
+	}
+	return "", false
+}
+
+// isTextual returns true for strings and for values whose text is provided by
+// String(), Error() or MarshalText() (like a time.Duration); their output is
+// subject to autoescaping.
+func (v *Value) isTextual() bool {
+	if v.IsString() {
+		return true
+	}
+	if v.IsNil() || v.isBuiltinType() || !v.val.CanInterface() {
+		return false
+	}
+	switch v.Interface().(type) {
+	case fmt.Stringer, error, encoding.TextMarshaler:
+		return true
+	}
+	return false
+}
+
 // Integer returns the underlying value as an integer (converts the underlying
 // value, if necessary). If it's not possible to convert the underlying value,
 // it will return 0.
@@ -295,26 +355,31 @@ func (v *Value) Slice(i, j int) *Value {
 	}
 }
 
-// Index gets the i-th item of an array, slice or string. Otherwise
-// it will return NIL.
+// Index gets the i-th item of an array, slice or string (the i-th rune);
+// negative indices count from the end (-1 is the last item). Otherwise or if
+// the index is out of range it will return NIL.
 func (v *Value) Index(i int) *Value {
 	switch v.getResolvedValue().Kind() {
 	case reflect.Array, reflect.Slice:
-		if i >= v.Len() {
+		if i < 0 {
+			i += v.Len()
+		}
+		if i < 0 || i >= v.Len() {
 			return AsValue(nil)
 		}
 		return AsValue(v.getResolvedValue().Index(i).Interface())
 	case reflect.String:
-		//return AsValue(v.getResolvedValue().Slice(i, i+1).Interface())
-		s := v.getResolvedValue().String()
-		runes := []rune(s)
-		if i < len(runes) {
-			return AsValue(string(runes[i]))
+		runes := []rune(v.getResolvedValue().String())
+		if i < 0 {
+			i += len(runes)
 		}
-		return AsValue("")
+		if i < 0 || i >= len(runes) {
+			return AsValue(nil)
+		}
+		return AsValue(string(runes[i]))
 	default:
-		logf("Value.Slice() not available for type: %s\n", v.getResolvedValue().Kind().String())
-		return AsValue([]int{})
+		logf("Value.Index() not available for type: %s\n", v.getResolvedValue().Kind().String())
+		return AsValue(nil)
 	}
 }
 
@@ -370,6 +435,16 @@ func (v *Value) CanSlice() bool {
 	return false
 }
 
+// IsIterable checks whether the underlying value is of type map, array, slice
+// or string and can be used with Iterate().
+func (v *Value) IsIterable() bool {
+	switch v.getResolvedValue().Kind() {
+	case reflect.Map, reflect.Array, reflect.Slice, reflect.String:
+		return true
+	}
+	return false
+}
+
 // Iterate iterates over a map, array, slice or a string. It calls the
 // function's first argument for every value with the following arguments:
 //
@@ -388,6 +463,11 @@ func (v *Value) Iterate(fn func(idx, count int, key, value *Value) bool, empty f
 // not affect the iteration through a map because maps don't have any particular order.
 // However, you can force an order using the `sorted` keyword (and even use `reversed sorted`).
 func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
+	if pairs, ok := v.Interface().(valuePairs); ok {
+		pairs.iterate(fn, empty, reverse, sorted)
+		return
+	}
+
 	switch v.getResolvedValue().Kind() {
 	case reflect.Map:
 		keys := sortedKeys(v.getResolvedValue().MapKeys())
@@ -487,12 +567,50 @@ func (v *Value) EqualValueTo(other *Value) bool {
 	if v.IsInteger() && other.IsInteger() {
 		return v.Integer() == other.Integer()
 	}
+	// numbers of mixed types (int and float) are compared as floats
+	if v.IsNumber() && other.IsNumber() {
+		return v.Float() == other.Float()
+	}
 	if v.IsTime() && other.IsTime() {
 		return v.Time().Equal(other.Time())
 	}
 	return v.Interface() == other.Interface()
 }
 
+// CompareTo compares the value with another one and returns -1, 0 or 1 if
+// the value is less than, equal to or greater than other. Numbers (with
+// int/float promotion), times and strings are comparable; two nil values are
+// considered equal. Any other combination returns an error.
+func (v *Value) CompareTo(other *Value) (int, *Error) {
+	switch {
+	case v.IsNil() && other.IsNil():
+		return 0, nil
+	case v.IsInteger() && other.IsInteger():
+		return compareOrdered(v.Integer() < other.Integer(), v.Integer() > other.Integer()), nil
+	case v.IsNumber() && other.IsNumber():
+		return compareOrdered(v.Float() < other.Float(), v.Float() > other.Float()), nil
+	case v.IsTime() && other.IsTime():
+		return compareOrdered(v.Time().Before(other.Time()), v.Time().After(other.Time())), nil
+	case v.IsString() && other.IsString():
+		return strings.Compare(v.String(), other.String()), nil
+	}
+	return 0, &Error{
+		Sender: "compare",
+		OrigError: fmt.Errorf("cannot compare values of type %s and %s",
+			v.getResolvedValue().Kind().String(), other.getResolvedValue().Kind().String()),
+	}
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
 type sortedKeys []reflect.Value
 
 func (sk sortedKeys) Len() int {
@@ -516,6 +634,37 @@ func (sk sortedKeys) Swap(i, j int) {
 	sk[i], sk[j] = sk[j], sk[i]
 }
 
+// valuePairs is an ordered list of key-value pairs (the result of the zip
+// filter). It's iterated like a map ({% for key, value in pairs %}), but
+// keeps its order; otherwise it behaves like a list of 2-item lists.
+type valuePairs [][2]interface{}
+
+func (pairs valuePairs) iterate(fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
+	order := make([]int, len(pairs))
+	for i := range order {
+		order[i] = i
+	}
+	if sorted {
+		sort.SliceStable(order, func(i, j int) bool {
+			return valuesList{AsValue(pairs[order[i]][0]), AsValue(pairs[order[j]][0])}.Less(0, 1)
+		})
+	}
+	if reverse {
+		for i := 0; i < len(order)/2; i++ {
+			order[i], order[len(order)-1-i] = order[len(order)-1-i], order[i]
+		}
+	}
+
+	for idx, i := range order {
+		if !fn(idx, len(pairs), AsValue(pairs[i][0]), AsValue(pairs[i][1])) {
+			return
+		}
+	}
+	if len(pairs) == 0 {
+		empty()
+	}
+}
+
 type valuesList []*Value
 
 func (vl valuesList) Len() int {
@@ -55,6 +55,10 @@ type variableResolver struct {
 	locationToken *Token
 
 	parts []*variablePart
+
+	// Set by resolveValuePath: the first part only holds the value the path
+	// is resolved on, so it's not part of the variable's name
+	valuePath bool
 }
 
 type nodeFilteredVariable struct {
@@ -191,9 +195,17 @@ func (nv *nodeVariable) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 		return err
 	}
 
-	if !nv.expr.FilterApplied("safe") && !value.safe && value.IsString() && ctx.Autoescape {
+	if nv.expr.FilterApplied("safe") {
+		// Values marked safe explicitly aren't modified by the whitespace
+		// tags either (unlike internally safe ones, like a macro's output)
+		writeProtected(writer, value.String())
+		return nil
+	}
+
+	if !value.safe && value.isTextual() && ctx.Autoescape && ctx.escapeModeEscapes(value) {
 		// apply escape filter
-		value, err = filters["escape"](value, nil)
+		escape, _ := lookupFilter("escape")
+		value, err = escape(value, nil)
 		if err != nil {
 			return err
 		}
@@ -213,7 +225,10 @@ func (vr *variableResolver) FilterApplied(name string) bool {
 
 func (vr *variableResolver) String() string {
 	parts := make([]string, 0, len(vr.parts))
-	for _, p := range vr.parts {
+	for i, p := range vr.parts {
+		if i == 0 && vr.valuePath {
+			continue
+		}
 		switch p.typ {
 		case varTypeInt:
 			parts = append(parts, strconv.Itoa(p.i))
@@ -228,17 +243,23 @@ func (vr *variableResolver) String() string {
 
 func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 	var current reflect.Value
-	var isSafe bool
+	var isSafe, isUnsafe bool
 
 	for idx, part := range vr.parts {
 		if idx == 0 {
 			// We're looking up the first part of the variable.
-			// First we're having a look in our private
-			// context (e. g. information provided by tags, like the forloop)
-			val, inPrivate := ctx.Private[vr.parts[0].s]
+			// First we're having a look in our private context
+			// (e. g. information provided by tags, like the forloop, and
+			// the values accumulated by the increment/append tags)
+			val, inPrivate := ctx.privateValue(vr.parts[0].s)
 			if !inPrivate {
 				// Nothing found? Then have a final lookup in the public context
 				val = ctx.Public[vr.parts[0].s]
+
+				// Values of keys marked by TemplateSet.SetSafeKeys() don't get escaped
+				if ctx.template != nil && ctx.template.set.isSafeKey(vr.parts[0].s) {
+					isSafe = true
+				}
 			}
 			current = reflect.ValueOf(val) // Get the initial value
 		} else {
@@ -314,6 +335,7 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 			tmpValue := current.Interface().(*Value)
 			current = tmpValue.val
 			isSafe = tmpValue.safe
+			isUnsafe = tmpValue.unsafe
 		}
 
 		// Check whether this is an interface and resolve it where required
@@ -425,6 +447,7 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 				// Return the function call value
 				current = rv.Interface().(*Value).val
 				isSafe = rv.Interface().(*Value).safe
+				isUnsafe = rv.Interface().(*Value).unsafe
 			}
 		}
 
@@ -434,13 +457,45 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 		}
 	}
 
-	return &Value{val: current, safe: isSafe}, nil
+	return &Value{val: current, safe: isSafe, unsafe: isUnsafe}, nil
+}
+
+// resolveValuePath resolves a dotted attribute path (like "address.city"
+// or "items.0") on the given value using the same lookup rules as the
+// template variable resolver (struct fields, map keys, indices and methods).
+// Missing attributes yield a nil value.
+func resolveValuePath(in *Value, path string) (*Value, error) {
+	if path == "" {
+		return in, nil
+	}
+
+	resolver := &variableResolver{
+		parts:     []*variablePart{{typ: varTypeIdent, s: "value"}},
+		valuePath: true,
+	}
+	for _, name := range strings.Split(path, ".") {
+		if i, err := strconv.Atoi(name); err == nil {
+			resolver.parts = append(resolver.parts, &variablePart{typ: varTypeInt, i: i})
+		} else {
+			resolver.parts = append(resolver.parts, &variablePart{typ: varTypeIdent, s: name})
+		}
+	}
+
+	ctx := &ExecutionContext{
+		Private: Context{"value": in},
+	}
+	return resolver.resolve(ctx)
 }
 
 func (vr *variableResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	value, err := vr.resolve(ctx)
 	if err != nil {
-		return AsValue(nil), ctx.Error(err.Error(), vr.locationToken)
+		newErr := ctx.OrigError(err, vr.locationToken)
+		if origErr, ok := err.(*Error); ok {
+			// e. g. an error within a macro call; keep its frames
+			newErr.stack = origErr.stack
+		}
+		return AsValue(nil), newErr
 	}
 	return value, nil
 }
@@ -456,20 +511,80 @@ func (v *nodeFilteredVariable) FilterApplied(name string) bool {
 
 func (v *nodeFilteredVariable) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	value, err := v.resolver.Evaluate(ctx)
-	if err != nil {
-		return nil, err
-	}
 
 	for _, filter := range v.filterChain {
-		value, err = filter.Execute(value, ctx)
 		if err != nil {
-			return nil, err
+			// The filters up to the next default_if_error are skipped
+			if filter.catchesErrors {
+				value, err = filter.executeOnError(ctx)
+			}
+			continue
 		}
+		value, err = filter.Execute(value, ctx)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return value, nil
 }
 
+// separatedIntResolver is an integer literal with digit separators, like
+// 1_000. Since identifiers may start with a digit (see issue #151), a
+// variable of the same name (like "12345_123") takes precedence.
+type separatedIntResolver struct {
+	number   *intResolver
+	variable *variableResolver
+}
+
+func (r *separatedIntResolver) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := r.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (r *separatedIntResolver) GetPositionToken() *Token {
+	return r.number.locationToken
+}
+
+func (r *separatedIntResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	name := r.variable.parts[0].s
+	if _, bound := ctx.privateValue(name); bound {
+		return r.variable.Evaluate(ctx)
+	}
+	if _, bound := ctx.Public[name]; bound {
+		return r.variable.Evaluate(ctx)
+	}
+	return r.number.Evaluate(ctx)
+}
+
+func (r *separatedIntResolver) FilterApplied(name string) bool {
+	return false
+}
+
+// parseIntegerLiteral converts the value of a number token into an int.
+// Besides plain decimal numbers it supports digit separators (1_000) and
+// the base prefixes 0x (hexadecimal), 0b (binary) and 0o (octal).
+func parseIntegerLiteral(s string) (int, error) {
+	if len(s) > 1 && s[0] == '0' && strings.ContainsRune("xXbBoO", rune(s[1])) {
+		i, err := strconv.ParseInt(s, 0, 64)
+		return int(i), err
+	}
+	return strconv.Atoi(strings.Replace(s, "_", "", -1))
+}
+
+// isExponentLiteral reports whether the value of a number token is written
+// in scientific notation (like 1e6 or 2E+4).
+func isExponentLiteral(s string) bool {
+	if len(s) > 1 && s[0] == '0' && strings.ContainsRune("xXbBoO", rune(s[1])) {
+		return false
+	}
+	return strings.ContainsAny(s, "eE")
+}
+
 // IDENT | IDENT.(IDENT|NUMBER)...
 func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 	t := p.Current()
@@ -494,9 +609,21 @@ func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 			if t2 == nil {
 				return nil, p.Error("Expected a number after the '.'.", nil)
 			}
-			f, err := strconv.ParseFloat(fmt.Sprintf("%s.%s", t.Val, t2.Val), 64)
+			f, err := strconv.ParseFloat(strings.Replace(fmt.Sprintf("%s.%s", t.Val, t2.Val), "_", "", -1), 64)
+			if err != nil {
+				return nil, p.OrigError(err, t)
+			}
+			fr := &floatResolver{
+				locationToken: t,
+				val:           f,
+			}
+			return fr, nil
+		}
+		if isExponentLiteral(t.Val) {
+			// float64 in scientific notation (1e6)
+			f, err := strconv.ParseFloat(strings.Replace(t.Val, "_", "", -1), 64)
 			if err != nil {
-				return nil, p.Error(err.Error(), t)
+				return nil, p.OrigError(err, t)
 			}
 			fr := &floatResolver{
 				locationToken: t,
@@ -504,14 +631,23 @@ func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 			}
 			return fr, nil
 		}
-		i, err := strconv.Atoi(t.Val)
+		i, err := parseIntegerLiteral(t.Val)
 		if err != nil {
-			return nil, p.Error(err.Error(), t)
+			return nil, p.OrigError(err, t)
 		}
 		nr := &intResolver{
 			locationToken: t,
 			val:           i,
 		}
+		if strings.Contains(t.Val, "_") {
+			return &separatedIntResolver{
+				number: nr,
+				variable: &variableResolver{
+					locationToken: t,
+					parts:         []*variablePart{{typ: varTypeIdent, s: t.Val}},
+				},
+			}, nil
+		}
 		return nr, nil
 
 	case TokenString:
@@ -521,6 +657,13 @@ func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 			val:           t.Val,
 		}
 		return sr, nil
+	case TokenSymbol:
+		switch t.Val {
+		case "[":
+			return p.parseListLiteral()
+		case "{":
+			return p.parseMapLiteral()
+		}
 	case TokenKeyword:
 		p.Consume()
 		switch t.Val {
@@ -574,9 +717,18 @@ variableLoop:
 					p.Consume() // consume: IDENT
 					continue variableLoop
 				case TokenNumber:
-					i, err := strconv.Atoi(t2.Val)
+					if strings.Contains(t2.Val, "_") {
+						// A key like mydict.51232_3 (see issue #151)
+						resolver.parts = append(resolver.parts, &variablePart{
+							typ: varTypeIdent,
+							s:   t2.Val,
+						})
+						p.Consume() // consume: NUMBER
+						continue variableLoop
+					}
+					i, err := parseIntegerLiteral(t2.Val)
 					if err != nil {
-						return nil, p.Error(err.Error(), t2)
+						return nil, p.OrigError(err, t2)
 					}
 					resolver.parts = append(resolver.parts, &variablePart{
 						typ: varTypeInt,